@@ -1,7 +1,10 @@
 package mcp
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"sync"
 )
 
 // Server MCP服务器
@@ -9,6 +12,7 @@ type Server struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
 	tools   map[string]*Tool
+	mutex   sync.RWMutex
 }
 
 // Tool MCP工具定义
@@ -16,21 +20,29 @@ type Tool struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	Arguments   map[string]interface{} `json:"arguments"`
+	Meta        map[string]interface{} `json:"_meta,omitempty"`
 	Handler     ToolHandler            `json:"-"`
 }
 
-// ToolHandler 工具处理函数
-type ToolHandler func(args map[string]interface{}) (*ToolResult, error)
+// ToolHandler 工具处理函数，ctx在客户端取消请求或连接断开时被取消
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (*ToolResult, error)
 
-// ToolResult 工具执行结果
+// ToolResult 工具执行结果，IsError表示工具执行失败（如参数不合法），错误信息在Content中
 type ToolResult struct {
 	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
 }
 
 // Content 内容结构
 type Content struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type        string       `json:"type"`
+	Text        string       `json:"text"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+// Annotations 内容注解，Role记录内容在prompt中的原始消息角色（system、user或assistant）
+type Annotations struct {
+	Role string `json:"role,omitempty"`
 }
 
 // MCPRequest MCP请求
@@ -49,6 +61,19 @@ type MCPResponse struct {
 	Error   *MCPError   `json:"error,omitempty"`
 }
 
+// MCPNotification MCP通知（无ID，不需要响应）
+type MCPNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// CancelledParams 取消通知参数
+type CancelledParams struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
 // MCPError MCP错误
 type MCPError struct {
 	Code    int         `json:"code"`
@@ -72,6 +97,45 @@ type ToolInfo struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	InputSchema map[string]interface{} `json:"inputSchema"`
+	Meta        map[string]interface{} `json:"_meta,omitempty"`
+}
+
+// PromptInfo prompt信息（对应MCP prompts/list中的单个prompt）
+type PromptInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Arguments   []PromptArgumentInfo   `json:"arguments,omitempty"`
+	Meta        map[string]interface{} `json:"_meta,omitempty"`
+}
+
+// PromptArgumentInfo prompt参数信息
+type PromptArgumentInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// ListPromptsResult prompt列表结果
+type ListPromptsResult struct {
+	Prompts []PromptInfo `json:"prompts"`
+}
+
+// GetPromptParams 获取prompt参数
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptMessage prompt消息
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// GetPromptResult 获取prompt结果
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
 }
 
 // ServerInfo 服务器信息
@@ -80,6 +144,26 @@ type ServerInfo struct {
 	Version string `json:"version"`
 }
 
+// ClientInfo 客户端信息，来自initialize请求
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// clientInfoKey context中保存客户端信息的键
+type clientInfoKey struct{}
+
+// WithClientInfo 返回携带客户端信息的context，供工具处理函数读取
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFromContext 从context中读取客户端信息
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
 // NewServer 创建新的MCP服务器
 func NewServer(name, version string) *Server {
 	return &Server{
@@ -99,18 +183,40 @@ func (s *Server) RegisterTool(tool *Tool) error {
 		return fmt.Errorf("tool handler cannot be nil")
 	}
 
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	s.tools[tool.Name] = tool
 	return nil
 }
 
+// UnregisterTool 注销工具，返回工具是否存在
+func (s *Server) UnregisterTool(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.tools[name]; !exists {
+		return false
+	}
+
+	delete(s.tools, name)
+	return true
+}
+
 // GetTool 获取工具
 func (s *Server) GetTool(name string) (*Tool, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	tool, exists := s.tools[name]
 	return tool, exists
 }
 
 // ListTools 列出所有工具
 func (s *Server) ListTools() []ToolInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	tools := make([]ToolInfo, 0, len(s.tools))
 
 	for _, tool := range s.tools {
@@ -118,21 +224,26 @@ func (s *Server) ListTools() []ToolInfo {
 			Name:        tool.Name,
 			Description: tool.Description,
 			InputSchema: buildInputSchema(tool.Arguments),
+			Meta:        tool.Meta,
 		}
 		tools = append(tools, toolInfo)
 	}
 
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+
 	return tools
 }
 
 // CallTool 调用工具
-func (s *Server) CallTool(name string, args map[string]interface{}) (*ToolResult, error) {
+func (s *Server) CallTool(ctx context.Context, name string, args map[string]interface{}) (*ToolResult, error) {
 	tool, exists := s.GetTool(name)
 	if !exists {
 		return nil, fmt.Errorf("tool not found: %s", name)
 	}
 
-	return tool.Handler(args)
+	return tool.Handler(ctx, args)
 }
 
 // GetServerInfo 获取服务器信息
@@ -143,26 +254,41 @@ func (s *Server) GetServerInfo() ServerInfo {
 	}
 }
 
-// buildInputSchema 构建输入schema
+// buildInputSchema 构建输入schema，参数中的"required": true会被收集到顶层required列表
 func buildInputSchema(arguments map[string]interface{}) map[string]interface{} {
-	schema := map[string]interface{}{
-		"type":       "object",
-		"properties": arguments,
-	}
-
-	// 提取必需的参数
+	properties := make(map[string]interface{}, len(arguments))
 	required := make([]string, 0)
+
 	for name, arg := range arguments {
-		if argMap, ok := arg.(map[string]interface{}); ok {
-			if req, exists := argMap["required"]; exists {
-				if isRequired, ok := req.(bool); ok && isRequired {
-					required = append(required, name)
+		argMap, ok := arg.(map[string]interface{})
+		if !ok {
+			properties[name] = arg
+			continue
+		}
+
+		// 复制一份，去掉非标准的required字段
+		property := make(map[string]interface{}, len(argMap))
+		for key, value := range argMap {
+			if key == "required" {
+				if isRequired, ok := value.(bool); ok {
+					if isRequired {
+						required = append(required, name)
+					}
+					continue
 				}
 			}
+			property[key] = value
 		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
 	}
 
 	if len(required) > 0 {
+		sort.Strings(required)
 		schema["required"] = required
 	}
 
@@ -0,0 +1,451 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 支持的参数类型
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeEnum    = "enum"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// ArgumentError 单个参数的校验错误
+type ArgumentError struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// ArgumentErrors 参数校验错误列表，包含所有不合法的参数
+type ArgumentErrors []ArgumentError
+
+// Error 实现error接口
+func (e ArgumentErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, argErr := range e {
+		parts[i] = fmt.Sprintf("%s: %s", argErr.Name, argErr.Message)
+	}
+	return "invalid arguments: " + strings.Join(parts, "; ")
+}
+
+// EffectiveType 返回参数类型，未声明时为string
+func (a *Argument) EffectiveType() string {
+	if a.Type == "" {
+		return TypeString
+	}
+	return a.Type
+}
+
+// IsRequired 调用方是否必须提供该参数；声明了默认值的参数省略时会使用默认值，因此不是必需的
+func (a *Argument) IsRequired() bool {
+	return a.Required && a.Default == nil
+}
+
+// Schema 生成参数对应的JSON Schema
+func (a *Argument) Schema() map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	if a.Description != "" {
+		schema["description"] = a.Description
+	}
+
+	switch a.EffectiveType() {
+	case TypeEnum:
+		schema["type"] = TypeString
+	default:
+		schema["type"] = a.EffectiveType()
+	}
+
+	if len(a.Enum) > 0 {
+		if values, err := a.enumValues(); err == nil {
+			schema["enum"] = values
+		}
+	}
+	if a.Pattern != "" {
+		schema["pattern"] = a.Pattern
+	}
+	if a.MinLength != nil {
+		schema["minLength"] = *a.MinLength
+	}
+	if a.MaxLength != nil {
+		schema["maxLength"] = *a.MaxLength
+	}
+	if a.Minimum != nil {
+		schema["minimum"] = *a.Minimum
+	}
+	if a.Maximum != nil {
+		schema["maximum"] = *a.Maximum
+	}
+	if a.EffectiveType() == TypeArray && a.Items != nil {
+		schema["items"] = a.Items.Schema()
+	}
+	if a.Default != nil {
+		if value, err := a.Coerce(a.Default); err == nil {
+			schema["default"] = value
+		}
+	}
+
+	return schema
+}
+
+// validateDefinition 检查参数定义本身是否合法
+func (a *Argument) validateDefinition() error {
+	switch a.EffectiveType() {
+	case TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeArray, TypeObject:
+	case TypeEnum:
+		if len(a.Enum) == 0 {
+			return fmt.Errorf("argument %s: enum type requires enum values", a.Name)
+		}
+	default:
+		return fmt.Errorf("argument %s: unsupported type %q", a.Name, a.Type)
+	}
+
+	if len(a.Enum) > 0 {
+		switch a.EffectiveType() {
+		case TypeArray, TypeObject:
+			return fmt.Errorf("argument %s: enum is not allowed for %s type", a.Name, a.EffectiveType())
+		}
+		if _, err := a.enumValues(); err != nil {
+			return fmt.Errorf("argument %s: invalid enum value: %w", a.Name, err)
+		}
+	}
+
+	if a.Pattern != "" {
+		if _, err := regexp.Compile(a.Pattern); err != nil {
+			return fmt.Errorf("argument %s: invalid pattern: %w", a.Name, err)
+		}
+	}
+
+	if a.MinLength != nil && a.MaxLength != nil && *a.MinLength > *a.MaxLength {
+		return fmt.Errorf("argument %s: minLength is greater than maxLength", a.Name)
+	}
+
+	if a.Minimum != nil && a.Maximum != nil && *a.Minimum > *a.Maximum {
+		return fmt.Errorf("argument %s: minimum is greater than maximum", a.Name)
+	}
+
+	// 空字符串默认值等同于未提供，无法满足required
+	if a.Required && a.Default == "" {
+		return fmt.Errorf("argument %s: required argument cannot have an empty default", a.Name)
+	}
+
+	if a.Default != nil {
+		if _, err := a.Coerce(a.Default); err != nil {
+			return fmt.Errorf("argument %s: invalid default: %w", a.Name, err)
+		}
+	}
+
+	if a.Items != nil {
+		if a.EffectiveType() != TypeArray {
+			return fmt.Errorf("argument %s: items is only allowed for array type", a.Name)
+		}
+		if err := a.Items.validateDefinition(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Coerce 将参数值转换为声明的类型并检查约束。
+// prompts/get的参数均为字符串，因此字符串会按类型解析
+func (a *Argument) Coerce(value interface{}) (interface{}, error) {
+	var (
+		coerced interface{}
+		err     error
+	)
+
+	value = normalizeValue(value)
+
+	switch a.EffectiveType() {
+	case TypeString, TypeEnum:
+		coerced, err = coerceString(value)
+	case TypeNumber:
+		coerced, err = coerceNumber(value)
+	case TypeInteger:
+		coerced, err = coerceInteger(value)
+	case TypeBoolean:
+		coerced, err = coerceBoolean(value)
+	case TypeArray:
+		coerced, err = a.coerceArray(value)
+	case TypeObject:
+		coerced, err = coerceObject(value)
+	default:
+		err = fmt.Errorf("unsupported type %q", a.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkConstraints(coerced); err != nil {
+		return nil, err
+	}
+
+	return coerced, nil
+}
+
+// checkConstraints 检查枚举、正则、长度和范围约束
+func (a *Argument) checkConstraints(value interface{}) error {
+	if len(a.Enum) > 0 {
+		options, err := a.enumValues()
+		if err != nil {
+			return err
+		}
+		text := formatValue(value)
+		allowed := false
+		texts := make([]string, len(options))
+		for i, option := range options {
+			texts[i] = formatValue(option)
+			if texts[i] == text {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("must be one of [%s]", strings.Join(texts, ", "))
+		}
+	}
+
+	if text, ok := value.(string); ok {
+		length := utf8.RuneCountInString(text)
+		if a.MinLength != nil && length < *a.MinLength {
+			return fmt.Errorf("length must be at least %d", *a.MinLength)
+		}
+		if a.MaxLength != nil && length > *a.MaxLength {
+			return fmt.Errorf("length must be at most %d", *a.MaxLength)
+		}
+		if a.Pattern != "" {
+			re, err := regexp.Compile(a.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+			if !re.MatchString(text) {
+				return fmt.Errorf("must match pattern %s", a.Pattern)
+			}
+		}
+	}
+
+	var number float64
+	switch v := value.(type) {
+	case float64:
+		number = v
+	case int64:
+		number = float64(v)
+	default:
+		return nil
+	}
+	if a.Minimum != nil && number < *a.Minimum {
+		return fmt.Errorf("must be >= %v", *a.Minimum)
+	}
+	if a.Maximum != nil && number > *a.Maximum {
+		return fmt.Errorf("must be <= %v", *a.Maximum)
+	}
+
+	return nil
+}
+
+// enumValues 将枚举值转换为参数声明的类型，如integer参数的 [1, 2] 发布为数字而不是字符串。
+// 枚举值在YAML或JSON中可以写成字符串或对应类型的值
+func (a *Argument) enumValues() ([]interface{}, error) {
+	values := make([]interface{}, len(a.Enum))
+	for i, option := range a.Enum {
+		var (
+			value interface{}
+			err   error
+		)
+		option = normalizeValue(option)
+		switch a.EffectiveType() {
+		case TypeNumber:
+			value, err = coerceNumber(option)
+		case TypeInteger:
+			value, err = coerceInteger(option)
+		case TypeBoolean:
+			value, err = coerceBoolean(option)
+		default:
+			value, err = coerceString(option)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", formatValue(option), err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// coerceArray 转换数组参数，字符串按JSON数组解析
+func (a *Argument) coerceArray(value interface{}) (interface{}, error) {
+	if text, ok := value.(string); ok {
+		var parsed []interface{}
+		if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+			return nil, fmt.Errorf("expected a JSON array")
+		}
+		value = parsed
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array")
+	}
+
+	if a.Items == nil {
+		return items, nil
+	}
+
+	coerced := make([]interface{}, len(items))
+	for i, item := range items {
+		v, err := a.Items.Coerce(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		coerced[i] = v
+	}
+
+	return coerced, nil
+}
+
+// normalizeValue 将YAML解析出的int等Go数值类型统一为int64或float64
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeValue(item)
+		}
+		return normalized
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeValue(item)
+		}
+		return normalized
+	default:
+		return v
+	}
+}
+
+// coerceString 转换字符串参数，标量值按文本表示接受
+func coerceString(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64, int64, bool:
+		return formatValue(v), nil
+	default:
+		return nil, fmt.Errorf("expected a string")
+	}
+}
+
+// coerceNumber 转换数字参数
+func coerceNumber(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return number, nil
+	default:
+		return nil, fmt.Errorf("expected a number")
+	}
+}
+
+// coerceInteger 转换整数参数
+func coerceInteger(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return int64(v), nil
+	case string:
+		number, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return number, nil
+	default:
+		return nil, fmt.Errorf("expected an integer")
+	}
+}
+
+// coerceBoolean 转换布尔参数
+func coerceBoolean(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("expected a boolean")
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("expected a boolean")
+	}
+}
+
+// coerceObject 转换对象参数，字符串按JSON对象解析
+func coerceObject(value interface{}) (interface{}, error) {
+	if text, ok := value.(string); ok {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+			return nil, fmt.Errorf("expected a JSON object")
+		}
+		return parsed, nil
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	return object, nil
+}
+
+// formatValue 将参数值格式化为替换到模板中的文本，数组和对象使用JSON
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
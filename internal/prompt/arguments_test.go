@@ -0,0 +1,185 @@
+package prompt
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonArgument 按JSON prompt文件的方式解析参数定义
+func jsonArgument(data string) Argument {
+	var arg Argument
+	if err := json.Unmarshal([]byte(data), &arg); err != nil {
+		panic(err)
+	}
+	return arg
+}
+
+func TestArgumentSchemaEnum(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  Argument
+		want []interface{}
+	}{
+		{name: "enum", arg: Argument{Type: TypeEnum, Enum: []interface{}{"basic", "detailed"}}, want: []interface{}{"basic", "detailed"}},
+		{name: "integer", arg: Argument{Type: TypeInteger, Enum: []interface{}{"1", "2"}}, want: []interface{}{int64(1), int64(2)}},
+		{name: "number", arg: Argument{Type: TypeNumber, Enum: []interface{}{"0.5", "1"}}, want: []interface{}{0.5, 1.0}},
+		{name: "boolean", arg: Argument{Type: TypeBoolean, Enum: []interface{}{"true"}}, want: []interface{}{true}},
+		{name: "yaml integers", arg: Argument{Type: TypeInteger, Enum: []interface{}{1, 2}}, want: []interface{}{int64(1), int64(2)}},
+		{name: "json integers", arg: jsonArgument(`{"name":"n","type":"integer","enum":[1,2]}`), want: []interface{}{int64(1), int64(2)}},
+		{name: "json booleans", arg: jsonArgument(`{"name":"b","type":"boolean","enum":[true]}`), want: []interface{}{true}},
+		{name: "json numbers for string type", arg: jsonArgument(`{"name":"s","enum":[1,"two"]}`), want: []interface{}{"1", "two"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.arg.Schema()["enum"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Schema()[enum] = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgumentValidateDefinitionEnum(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     Argument
+		wantErr string
+	}{
+		{name: "integer enum", arg: Argument{Name: "n", Type: TypeInteger, Enum: []interface{}{"1", "2"}}},
+		{name: "non-integer value", arg: Argument{Name: "n", Type: TypeInteger, Enum: []interface{}{"1", "x"}}, wantErr: "invalid enum value"},
+		{name: "array enum", arg: Argument{Name: "n", Type: TypeArray, Enum: []interface{}{"[]"}}, wantErr: "not allowed for array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.arg.validateDefinition()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateDefinition() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateDefinition() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArgumentValidateDefinitionDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     Argument
+		wantErr string
+	}{
+		{name: "required with default", arg: Argument{Name: "a", Required: true, Default: "go"}},
+		{name: "optional with empty default", arg: Argument{Name: "a", Default: ""}},
+		{name: "required with empty default", arg: Argument{Name: "a", Required: true, Default: ""}, wantErr: "cannot have an empty default"},
+		{name: "default of wrong type", arg: Argument{Name: "a", Type: TypeInteger, Default: "x"}, wantErr: "invalid default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.arg.validateDefinition()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateDefinition() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateDefinition() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArgumentIsRequired(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  Argument
+		want bool
+	}{
+		{name: "required", arg: Argument{Required: true}, want: true},
+		{name: "required with default", arg: Argument{Required: true, Default: "go"}, want: false},
+		{name: "optional", arg: Argument{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.arg.IsRequired(); got != tt.want {
+				t.Errorf("IsRequired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgumentCoerce(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		arg     Argument
+		value   interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{name: "string", arg: Argument{}, value: "text", want: "text"},
+		{name: "string from number", arg: Argument{Type: TypeString}, value: 1.5, want: "1.5"},
+		{name: "string rejects array", arg: Argument{Type: TypeString}, value: []interface{}{"a"}, wantErr: "expected a string"},
+		{name: "number from string", arg: Argument{Type: TypeNumber}, value: " 2.5 ", want: 2.5},
+		{name: "number from yaml int", arg: Argument{Type: TypeNumber}, value: 2, want: 2.0},
+		{name: "number rejects text", arg: Argument{Type: TypeNumber}, value: "two", wantErr: "expected a number"},
+		{name: "integer from string", arg: Argument{Type: TypeInteger}, value: "42", want: int64(42)},
+		{name: "integer from whole float", arg: Argument{Type: TypeInteger}, value: 3.0, want: int64(3)},
+		{name: "integer rejects fraction", arg: Argument{Type: TypeInteger}, value: 3.5, wantErr: "expected an integer"},
+		{name: "boolean from string", arg: Argument{Type: TypeBoolean}, value: "true", want: true},
+		{name: "boolean rejects text", arg: Argument{Type: TypeBoolean}, value: "yes", wantErr: "expected a boolean"},
+		{name: "array from json", arg: Argument{Type: TypeArray}, value: `["a", 1]`, want: []interface{}{"a", 1.0}},
+		{
+			name:  "array items coerced",
+			arg:   Argument{Type: TypeArray, Items: &Argument{Type: TypeInteger}},
+			value: []interface{}{"1", 2},
+			want:  []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:    "array item error",
+			arg:     Argument{Type: TypeArray, Items: &Argument{Type: TypeInteger}},
+			value:   []interface{}{"1", "x"},
+			wantErr: "item 1: expected an integer",
+		},
+		{name: "array rejects object json", arg: Argument{Type: TypeArray}, value: `{"a":1}`, wantErr: "expected a JSON array"},
+		{name: "object from json", arg: Argument{Type: TypeObject}, value: `{"a":"b"}`, want: map[string]interface{}{"a": "b"}},
+		{name: "object rejects scalar", arg: Argument{Type: TypeObject}, value: true, wantErr: "expected an object"},
+		{name: "enum allowed", arg: Argument{Type: TypeEnum, Enum: []interface{}{"basic", "detailed"}}, value: "basic", want: "basic"},
+		{name: "enum rejected", arg: Argument{Type: TypeEnum, Enum: []interface{}{"basic", "detailed"}}, value: "full", wantErr: "must be one of [basic, detailed]"},
+		{name: "number enum", arg: Argument{Type: TypeNumber, Enum: []interface{}{"1.0", "2.5"}}, value: "1", want: 1.0},
+		{name: "minLength counts runes", arg: Argument{MinLength: intPtr(3)}, value: "中文", wantErr: "length must be at least 3"},
+		{name: "maxLength", arg: Argument{MaxLength: intPtr(2)}, value: "abc", wantErr: "length must be at most 2"},
+		{name: "pattern", arg: Argument{Pattern: "^[a-z]+$"}, value: "Go", wantErr: "must match pattern"},
+		{name: "minimum", arg: Argument{Type: TypeInteger, Minimum: floatPtr(1)}, value: "0", wantErr: "must be >= 1"},
+		{name: "maximum", arg: Argument{Type: TypeNumber, Maximum: floatPtr(10)}, value: 10.5, wantErr: "must be <= 10"},
+		{name: "unsupported type", arg: Argument{Type: "date"}, value: "2024-01-01", wantErr: "unsupported type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.arg.Coerce(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Coerce() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Coerce() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Coerce() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
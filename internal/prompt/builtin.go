@@ -0,0 +1,46 @@
+package prompt
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+// BuiltinNamespace 内置变量的保留命名空间，模板中通过 {{ctx.date}} 等方式引用
+const BuiltinNamespace = "ctx"
+
+// Environment 生成内置变量所需的服务器环境信息
+type Environment struct {
+	// ServerVersion 服务器版本
+	ServerVersion string
+	// Location 日期和时间使用的时区，为nil时使用本地时区
+	Location *time.Location
+	// Workspace 工作区根目录
+	Workspace string
+}
+
+// Variables 生成当前时刻的内置变量，clientName和clientVersion来自客户端的initialize请求
+func (e Environment) Variables(clientName, clientVersion string) map[string]interface{} {
+	location := e.Location
+	if location == nil {
+		location = time.Local
+	}
+	now := time.Now().In(location)
+
+	hostname, _ := os.Hostname()
+
+	return map[string]interface{}{
+		"date":           now.Format("2006-01-02"),
+		"time":           now.Format("15:04:05"),
+		"datetime":       now.Format(time.RFC3339),
+		"weekday":        now.Weekday().String(),
+		"timezone":       location.String(),
+		"server_version": e.ServerVersion,
+		"client_name":    clientName,
+		"client_version": clientVersion,
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"hostname":       hostname,
+		"workspace":      e.Workspace,
+	}
+}
@@ -0,0 +1,147 @@
+package prompt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 诊断的错误类型
+const (
+	DiagnosticRead       = "read"       // 文件无法读取
+	DiagnosticParse      = "parse"      // YAML/JSON语法或结构错误
+	DiagnosticCollision  = "collision"  // prompt名称与其他文件重复
+	DiagnosticExtends    = "extends"    // 继承的父prompt不存在、循环继承或消息块无法合并
+	DiagnosticInclude    = "include"    // 引用的prompt不存在或循环引用
+	DiagnosticValidation = "validation" // prompt定义不合法
+	DiagnosticTemplate   = "template"   // 模板编译失败
+)
+
+// Diagnostic 加载prompt文件时产生的一条结构化错误，Line和Column为0表示位置未知
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Kind    string `json:"kind"`
+	Prompt  string `json:"prompt,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error 以 file:line:column: kind: message 的格式返回诊断
+func (d *Diagnostic) Error() string {
+	location := d.File
+	if d.Line > 0 {
+		location += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			location += ":" + strconv.Itoa(d.Column)
+		}
+	}
+
+	if d.Prompt != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", location, d.Kind, d.Prompt, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, d.Kind, d.Message)
+}
+
+// yamlLinePattern 从yaml.v3的错误信息中提取行号
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// yamlDiagnostic 将YAML解析错误转换为诊断。yaml.v3的错误只有行号：
+// 解码错误（root不为nil）取该行上出错节点的列号，语法错误取该行第一个非空白字符的列号
+func yamlDiagnostic(file string, data []byte, root *yaml.Node, err error) *Diagnostic {
+	d := &Diagnostic{File: file, Kind: DiagnosticParse, Message: err.Error()}
+
+	groups := yamlLinePattern.FindStringSubmatch(err.Error())
+	if groups == nil {
+		return d
+	}
+	d.Line, _ = strconv.Atoi(groups[1])
+
+	if root != nil {
+		if node := yamlErrorNode(root, d.Line, err.Error()); node != nil {
+			d.Column = node.Column
+			return d
+		}
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if d.Line > 0 && d.Line <= len(lines) {
+		text := lines[d.Line-1]
+		d.Column = utf8.RuneCountInString(text[:len(text)-len(strings.TrimLeft(text, " \t"))]) + 1
+	}
+	return d
+}
+
+// yamlErrorNode 根据解码错误信息查找出错的节点：重复的键指向键，类型不符指向对应类型的值
+func yamlErrorNode(root *yaml.Node, line int, message string) *yaml.Node {
+	switch {
+	case strings.Contains(message, "already defined"):
+		return nodeAtLine(root, line, yaml.ScalarNode, true)
+	case strings.Contains(message, "cannot unmarshal !!seq"):
+		return nodeAtLine(root, line, yaml.SequenceNode, false)
+	case strings.Contains(message, "cannot unmarshal !!map"):
+		return nodeAtLine(root, line, yaml.MappingNode, false)
+	default:
+		return nodeAtLine(root, line, yaml.ScalarNode, false)
+	}
+}
+
+// jsonDiagnostic 将JSON解析错误转换为诊断，根据出错的字节偏移计算行列号
+func jsonDiagnostic(file string, data []byte, err error) *Diagnostic {
+	d := &Diagnostic{File: file, Kind: DiagnosticParse, Message: err.Error()}
+
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset为读取出错字符之后的偏移
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+
+	if offset >= 0 {
+		d.Line, d.Column = offsetPosition(data, offset)
+	}
+	return d
+}
+
+// offsetPosition 将字节偏移转换为从1开始的行号和列号
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// sortDiagnostics 按文件和位置排序
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
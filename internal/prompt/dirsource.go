@@ -0,0 +1,280 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce 合并连续文件事件的等待时间，编辑器分多步保存时只触发一次重新加载
+const watchDebounce = 200 * time.Millisecond
+
+// DirSource 磁盘目录来源，递归监控目录及其子目录的变化
+type DirSource struct {
+	name string
+	dir  string
+}
+
+// NewDirSource 创建磁盘目录来源，目录不存在时视为空来源
+func NewDirSource(name, dir string) *DirSource {
+	return &DirSource{name: name, dir: dir}
+}
+
+// Name 来源名称
+func (s *DirSource) Name() string {
+	return s.name
+}
+
+// Location 来源目录
+func (s *DirSource) Location() string {
+	return s.dir
+}
+
+// List 递归列出目录中所有prompt文件
+func (s *DirSource) List() ([]string, error) {
+	if !isDir(s.dir) {
+		return nil, nil
+	}
+	return listPromptFiles(os.DirFS(s.dir))
+}
+
+// Read 读取文件内容
+func (s *DirSource) Read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(name)))
+}
+
+// Watch 递归监控目录，文件变化合并后调用一次changed。目录不存在时监控最近的已存在上级目录，
+// 目录被创建后改为监控该目录并重新加载；目录被删除后同样回到等待状态。
+// 返回的函数关闭监控并等待监控goroutine退出，尚未触发的回调会被丢弃
+func (s *DirSource) Watch(changed func()) (func() error, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	if err := s.watchRoot(watcher); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch prompts directory %s: %w", s.dir, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.watchLoop(watcher, changed)
+	}()
+
+	return func() error {
+		err := watcher.Close()
+		<-done
+		return err
+	}, nil
+}
+
+// watchRoot 目录存在时将目录及所有子目录加入监控，不存在时监控最近的已存在上级目录。
+// 目录在加入监控的过程中被删除时重新选择
+func (s *DirSource) watchRoot(watcher *fsnotify.Watcher) error {
+	for {
+		var err error
+		if isDir(s.dir) {
+			err = addWatchDirs(watcher, s.dir)
+		} else if parent := existingParent(s.dir); parent != "" {
+			log.Printf("Prompts directory %s does not exist, waiting for it to be created in %s", s.dir, parent)
+			err = watcher.Add(parent)
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+}
+
+// rewatch 来源目录被创建或删除、或通往它的上级目录变化后，重新选择监控的目录，返回来源目录是否存在
+func (s *DirSource) rewatch(watcher *fsnotify.Watcher) bool {
+	root := filepath.Clean(s.dir)
+	for _, path := range watcher.WatchList() {
+		if !withinDir(path, root) {
+			_ = watcher.Remove(path)
+		}
+	}
+
+	if err := s.watchRoot(watcher); err != nil {
+		log.Printf("Warning: Failed to watch prompts directory %s: %v", s.dir, err)
+	}
+	return isDir(s.dir)
+}
+
+// handleEvent 处理一个文件事件，返回是否需要重新加载
+func (s *DirSource) handleEvent(watcher *fsnotify.Watcher, event fsnotify.Event) bool {
+	root := filepath.Clean(s.dir)
+	name := filepath.Clean(event.Name)
+
+	// 等待来源目录创建时，只有通往来源目录的上级目录的变化需要处理
+	if !withinDir(name, root) {
+		if isParentDir(name, root) {
+			return s.rewatch(watcher)
+		}
+		return false
+	}
+
+	// 来源目录本身被创建、删除或移走时，在目录和上级目录之间切换监控
+	if name == root && (event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) {
+		removeWatchDirs(watcher, root)
+		s.rewatch(watcher)
+		return true
+	}
+
+	return handleWatchEvent(watcher, event)
+}
+
+// watchLoop 处理文件事件，在事件停止watchDebounce后调用changed
+func (s *DirSource) watchLoop(watcher *fsnotify.Watcher, changed func()) {
+	var (
+		timer *time.Timer
+		fire  <-chan time.Time
+		paths = make(map[string]bool)
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if !s.handleEvent(watcher, event) {
+				continue
+			}
+			paths[event.Name] = true
+
+			// 每个新事件都重新计时
+			if timer == nil {
+				timer = time.NewTimer(watchDebounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(watchDebounce)
+			}
+			fire = timer.C
+
+		case <-fire:
+			fire = nil
+			log.Printf("Detected changes in %d paths in %s source, reloading prompts...", len(paths), s.name)
+			paths = make(map[string]bool)
+			changed()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("File watcher error: %v", err)
+		}
+	}
+}
+
+// handleWatchEvent 维护被监控的目录集合，返回该事件是否需要重新加载
+func handleWatchEvent(watcher *fsnotify.Watcher, event fsnotify.Event) bool {
+	switch {
+	case event.Has(fsnotify.Create):
+		// 新建或移入的目录需要加入监控，其中可能已经包含prompt文件
+		if isDir(event.Name) {
+			if err := addWatchDirs(watcher, event.Name); err != nil {
+				log.Printf("Warning: Failed to watch directory %s: %v", event.Name, err)
+			}
+			return true
+		}
+		return isPromptFile(event.Name)
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// 删除或移出的目录及其子目录不再监控，其中的prompt需要随之移除
+		if removeWatchDirs(watcher, event.Name) {
+			return true
+		}
+		return isPromptFile(event.Name)
+
+	case event.Has(fsnotify.Write):
+		return isPromptFile(event.Name)
+	}
+
+	return false
+}
+
+// addWatchDirs 将目录及其所有子目录加入监控
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return watcher.Add(path)
+	})
+}
+
+// removeWatchDirs 移除对目录及其子目录的监控，返回该路径是否是被监控的目录
+func removeWatchDirs(watcher *fsnotify.Watcher, root string) bool {
+	prefix := root + string(filepath.Separator)
+	removed := false
+	for _, path := range watcher.WatchList() {
+		if path == root || strings.HasPrefix(path, prefix) {
+			// 目录已删除时监控可能已被自动移除，忽略错误
+			_ = watcher.Remove(path)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// existingParent 返回路径最近的已存在上级目录，都不存在时返回空字符串
+func existingParent(path string) string {
+	dir := filepath.Clean(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		if isDir(parent) {
+			return parent
+		}
+		dir = parent
+	}
+}
+
+// withinDir 判断path是否是dir本身或位于dir之下
+func withinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// isParentDir 判断dir是否是path的上级目录
+func isParentDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isPromptFile 判断是否是支持的prompt文件类型
+func isPromptFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml" || ext == ".json"
+}
+
+// isDir 判断路径是否是已存在的目录
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
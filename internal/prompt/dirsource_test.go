@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitChanged 等待一次变化回调，超时则测试失败
+func waitChanged(t *testing.T, changed <-chan struct{}, what string) {
+	t.Helper()
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for reload after %s", what)
+	}
+}
+
+// writePromptFile 在目录中写入一个prompt文件
+func writePromptFile(t *testing.T, dir, file, name string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, file), promptFile(name, name), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestDirSourceWatchMissingDir(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "a", "b", "prompts")
+	source := NewDirSource(SourceProject, dir)
+
+	changed := make(chan struct{}, 16)
+	stop, err := source.Watch(func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer stop()
+
+	// 逐级创建上级目录，最后创建来源目录
+	for _, path := range []string{filepath.Join(base, "a"), filepath.Join(base, "a", "b")} {
+		if err := os.Mkdir(path, 0o755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	writePromptFile(t, dir, "first.yaml", "first")
+	waitChanged(t, changed, "creating the source directory")
+
+	files, err := source.List()
+	if err != nil || len(files) != 1 || files[0] != "first.yaml" {
+		t.Errorf("List() = %v, %v, want [first.yaml]", files, err)
+	}
+
+	// 目录出现后其中的变化正常触发重新加载
+	writePromptFile(t, dir, "second.yaml", "second")
+	waitChanged(t, changed, "adding a file")
+
+	// 目录被删除后回到等待状态，重新创建时再次加载
+	if err := os.RemoveAll(filepath.Join(base, "a")); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	waitChanged(t, changed, "removing the source directory")
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	writePromptFile(t, dir, "third.yaml", "third")
+	waitChanged(t, changed, "recreating the source directory")
+
+	files, err = source.List()
+	if err != nil || len(files) != 1 || files[0] != "third.yaml" {
+		t.Errorf("List() = %v, %v, want [third.yaml]", files, err)
+	}
+}
+
+func TestDirSourceWatchIgnoresSiblings(t *testing.T) {
+	base := t.TempDir()
+	source := NewDirSource(SourceProject, filepath.Join(base, "prompts"))
+
+	changed := make(chan struct{}, 16)
+	stop, err := source.Watch(func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer stop()
+
+	writePromptFile(t, base, "other.yaml", "other")
+	if err := os.Mkdir(filepath.Join(base, "other"), 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+
+	select {
+	case <-changed:
+		t.Errorf("changes next to the missing source directory triggered a reload")
+	case <-time.After(2 * watchDebounce):
+	}
+}
@@ -0,0 +1,95 @@
+package prompt
+
+import "fmt"
+
+// resolveExtends 合并所有prompt的继承关系，父prompt先于子prompt合并，返回合并失败的prompt及其错误
+func resolveExtends(prompts map[string]*Prompt) map[string]error {
+	return resolveDependencies("extends", prompts, mergeExtends)
+}
+
+// mergeExtends 将声明了extends的prompt与其父prompt合并
+func mergeExtends(r *dependencyResolver, child *Prompt) error {
+	if child.Extends == "" {
+		return nil
+	}
+
+	parentName, exists := lookupReference(r.prompts, child.Name, child.Namespace, child.Extends)
+	if !exists {
+		return atPosition(child.positions.extends, fmt.Errorf("unknown parent prompt: %s", child.Extends))
+	}
+	if err := r.resolve(parentName); err != nil {
+		return atPosition(child.positions.extends, err)
+	}
+
+	return child.mergeParent(r.prompts[parentName])
+}
+
+// mergeParent 以父prompt为基础应用子prompt的覆盖：
+// 描述等字段未设置时继承；同名参数替换、新参数追加；同名消息块原位替换、其余消息追加。
+// 父prompt中不存在的命名消息块会作为新消息追加，此时必须声明角色
+func (p *Prompt) mergeParent(parent *Prompt) error {
+	if p.Description == "" {
+		p.Description = parent.Description
+	}
+	if p.Engine == "" {
+		p.Engine = parent.Engine
+	}
+	if !p.Strict {
+		p.Strict = parent.Strict
+	}
+	if p.Text == "" {
+		p.Text = parent.Text
+	}
+	p.Metadata.inherit(&parent.Metadata)
+
+	// 合并参数
+	arguments := append([]Argument{}, parent.Arguments...)
+	argIndex := make(map[string]int, len(arguments))
+	for i, arg := range arguments {
+		argIndex[arg.Name] = i
+	}
+	for _, arg := range p.Arguments {
+		if i, exists := argIndex[arg.Name]; exists {
+			arguments[i] = arg
+			continue
+		}
+		argIndex[arg.Name] = len(arguments)
+		arguments = append(arguments, arg)
+	}
+	p.Arguments = arguments
+
+	// 合并消息块
+	messages := append([]Message{}, parent.Messages...)
+	msgIndex := make(map[string]int, len(messages))
+	for i, message := range messages {
+		if message.Name != "" {
+			msgIndex[message.Name] = i
+		}
+	}
+	for _, message := range p.Messages {
+		if message.Name == "" {
+			messages = append(messages, message)
+			continue
+		}
+		if i, exists := msgIndex[message.Name]; exists {
+			// 覆盖消息块时可以省略角色和内容类型
+			if message.Role == "" {
+				message.Role = messages[i].Role
+			}
+			if message.Content.Type == "" {
+				message.Content.Type = messages[i].Content.Type
+			}
+			messages[i] = message
+			continue
+		}
+		if message.Role == "" {
+			return atPosition(message.pos, fmt.Errorf("message block %q not found in parent prompt %s; a new block must declare its role",
+				message.Name, parent.Name))
+		}
+		msgIndex[message.Name] = len(messages)
+		messages = append(messages, message)
+	}
+	p.Messages = messages
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveExtendsMessageBlocks(t *testing.T) {
+	parent := func() *Prompt {
+		return &Prompt{
+			Name: "landing_page_html",
+			Messages: []Message{
+				{Name: "role", Role: RoleUser, Content: Content{Type: "text", Text: "role"}},
+				{Name: "style", Role: RoleUser, Content: Content{Type: "text", Text: "plain style"}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		blocks  []Message
+		wantErr string
+		want    []string
+	}{
+		{
+			name:   "override named block",
+			blocks: []Message{{Name: "style", Content: Content{Text: "kids style"}}},
+			want:   []string{"role", "kids style"},
+		},
+		{
+			name:   "append new block with role",
+			blocks: []Message{{Name: "extra", Role: RoleUser, Content: Content{Type: "text", Text: "extra"}}},
+			want:   []string{"role", "plain style", "extra"},
+		},
+		{
+			name:    "unknown block without role",
+			blocks:  []Message{{Name: "styel", Content: Content{Text: "typo"}}},
+			wantErr: `message block "styel" not found in parent prompt landing_page_html`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts := map[string]*Prompt{
+				"landing_page_html": parent(),
+				"kids":              {Name: "kids", Extends: "landing_page_html", Messages: tt.blocks},
+			}
+
+			err := resolveExtends(prompts)["kids"]
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveExtends() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveExtends() error = %v", err)
+			}
+
+			kids := prompts["kids"]
+			if err := kids.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			var got []string
+			for _, message := range kids.Messages {
+				got = append(got, message.Content.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEmptyContentType(t *testing.T) {
+	p := &Prompt{
+		Name:     "p",
+		Messages: []Message{{Role: RoleUser, Content: Content{Text: "hello"}}},
+	}
+
+	err := p.Validate()
+	if err == nil || !strings.Contains(err.Error(), "content type cannot be empty") {
+		t.Fatalf("Validate() error = %v, want content type error", err)
+	}
+}
+
+func TestResolveExtendsCycles(t *testing.T) {
+	child := func(name, parent string) *Prompt {
+		return &Prompt{
+			Name:     name,
+			Extends:  parent,
+			Messages: []Message{{Role: RoleUser, Content: Content{Type: "text", Text: name}}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		prompts []*Prompt
+		want    map[string]string
+		// 依赖循环但不在循环中的prompt，不应出现在循环路径里
+		outside string
+	}{
+		{
+			name:    "self extends",
+			prompts: []*Prompt{child("a", ".a")},
+			want:    map[string]string{"a": "extends cycle: a -> a"},
+		},
+		{
+			name:    "three prompts",
+			prompts: []*Prompt{child("a", "b"), child("b", "c"), child("c", "a")},
+			want:    map[string]string{"a": "extends cycle", "b": "extends cycle", "c": "extends cycle"},
+		},
+		{
+			name:    "child of a cycle reports only the cycle",
+			prompts: []*Prompt{child("leaf", "a"), child("a", "b"), child("b", "a")},
+			want:    map[string]string{"leaf": "extends cycle", "a": "extends cycle", "b": "extends cycle"},
+			outside: "leaf",
+		},
+		{
+			name:    "chain is not a cycle",
+			prompts: []*Prompt{child("a", "b"), child("b", "c"), child("c", "")},
+			want:    map[string]string{},
+		},
+		{
+			name:    "unknown parent",
+			prompts: []*Prompt{child("a", "missing")},
+			want:    map[string]string{"a": "unknown parent prompt: missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts := make(map[string]*Prompt, len(tt.prompts))
+			for _, p := range tt.prompts {
+				prompts[p.Name] = p
+			}
+
+			failed := resolveExtends(prompts)
+			if len(failed) != len(tt.want) {
+				t.Fatalf("resolveExtends() failed = %v, want %d failures", failed, len(tt.want))
+			}
+			for name, want := range tt.want {
+				if err := failed[name]; err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("error for %s = %v, want containing %q", name, err, want)
+				}
+			}
+			if err := failed[tt.outside]; err != nil && tt.outside != "" && strings.Contains(err.Error(), tt.outside) {
+				t.Errorf("error for %s = %v, want only the cycle", tt.outside, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// includePattern 匹配 {{> name}} 格式的引用，name可以是partial或其他prompt；
+// 以 \{{ 转义的引用不展开
+var includePattern = regexp.MustCompile(`(\\?)\{\{>\s*([\p{L}\p{N}_.\-]+)\s*\}\}`)
+
+// resolveIncludes 展开所有prompt中的引用，被引用的prompt先展开，返回展开失败的prompt及其错误
+func resolveIncludes(prompts map[string]*Prompt) map[string]error {
+	return resolveDependencies("include", prompts, expandIncludes)
+}
+
+// expandIncludes 替换prompt正文和消息中的引用，并合并被引用prompt声明的参数
+func expandIncludes(r *dependencyResolver, p *Prompt) error {
+	var includes []*Prompt
+
+	replace := func(text string) (string, error) {
+		var expandErr error
+		expanded := includePattern.ReplaceAllStringFunc(text, func(match string) string {
+			if expandErr != nil {
+				return match
+			}
+
+			groups := includePattern.FindStringSubmatch(match)
+			if groups[1] != "" {
+				return match
+			}
+
+			name, exists := lookupReference(r.prompts, p.Name, p.Namespace, groups[2])
+			if !exists {
+				expandErr = fmt.Errorf("unknown include: %s", groups[2])
+				return match
+			}
+			included := r.prompts[name]
+
+			if err := r.resolve(name); err != nil {
+				expandErr = err
+				return match
+			}
+
+			if err := checkIncludeEngine(p, included); err != nil {
+				expandErr = err
+				return match
+			}
+
+			includes = append(includes, included)
+			return included.body()
+		})
+
+		return expanded, expandErr
+	}
+
+	text, err := replace(p.Text)
+	if err != nil {
+		return atPosition(p.positions.text, err)
+	}
+	p.Text = text
+
+	for i := range p.Messages {
+		if p.Messages[i].Content.Type != "text" {
+			continue
+		}
+
+		text, err := replace(p.Messages[i].Content.Text)
+		if err != nil {
+			return atPosition(p.Messages[i].textPos, err)
+		}
+		p.Messages[i].Content.Text = text
+	}
+
+	// 被引用内容中的占位符需要对应的参数，未声明的参数从被引用的prompt继承
+	declared := make(map[string]bool, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		declared[arg.Name] = true
+	}
+	for _, included := range includes {
+		for _, arg := range included.Arguments {
+			if !declared[arg.Name] {
+				declared[arg.Name] = true
+				p.Arguments = append(p.Arguments, arg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// body 返回prompt被引用时插入的文本：partial的text，或所有文本消息依次拼接
+func (p *Prompt) body() string {
+	if p.Text != "" {
+		return strings.TrimRight(p.Text, "\n")
+	}
+
+	parts := make([]string, 0, len(p.Messages))
+	for _, message := range p.Messages {
+		if message.Content.Type == "text" {
+			parts = append(parts, strings.TrimRight(message.Content.Text, "\n"))
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// checkIncludeEngine 引用的内容按引用方的引擎解析，两者引擎不同且被引用内容包含模板语法时无法正确渲染
+func checkIncludeEngine(p, included *Prompt) error {
+	host, source := p.engine(), included.engine()
+	if host == source || !strings.Contains(included.body(), openDelim) {
+		return nil
+	}
+
+	return fmt.Errorf("cannot include %s (engine %s) into a prompt using engine %s: the included text contains template syntax",
+		included.Name, source, host)
+}
+
+// engine 返回prompt使用的模板引擎，未设置时为simple
+func (p *Prompt) engine() string {
+	if p.Engine == "" {
+		return EngineSimple
+	}
+	return p.Engine
+}
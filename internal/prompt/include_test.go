@@ -0,0 +1,136 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveIncludesMixedEngines(t *testing.T) {
+	tests := []struct {
+		name     string
+		partial  string
+		engine   string
+		wantErr  string
+		wantText string
+	}{
+		{
+			name:    "simple placeholders into gotemplate",
+			partial: "about {{topic}}",
+			engine:  EngineGoTemplate,
+			wantErr: "engine simple",
+		},
+		{
+			name:     "plain text into gotemplate",
+			partial:  "plain text",
+			engine:   EngineGoTemplate,
+			wantText: "intro plain text",
+		},
+		{
+			name:     "same engine",
+			partial:  "about {{topic}}",
+			engine:   EngineSimple,
+			wantText: "intro about {{topic}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts := map[string]*Prompt{
+				"part": {Name: "part", Partial: true, Text: tt.partial},
+				"host": {
+					Name:     "host",
+					Engine:   tt.engine,
+					Messages: []Message{{Role: RoleUser, Content: Content{Type: "text", Text: "intro {{> part}}"}}},
+				},
+			}
+
+			failed := resolveIncludes(prompts)
+
+			err := failed["host"]
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveIncludes() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveIncludes() error = %v", err)
+			}
+			if got := prompts["host"].Messages[0].Content.Text; got != tt.wantText {
+				t.Errorf("expanded text = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestResolveIncludesCycles(t *testing.T) {
+	text := func(name, body string) *Prompt {
+		return &Prompt{Name: name, Partial: true, Text: body}
+	}
+
+	tests := []struct {
+		name    string
+		prompts []*Prompt
+		want    map[string]string
+		// 依赖循环但不在循环中的prompt，不应出现在循环路径里
+		outside string
+	}{
+		{
+			name:    "self include",
+			prompts: []*Prompt{text("a", "{{> .a}}")},
+			want:    map[string]string{"a": "include cycle: a -> a"},
+		},
+		{
+			name:    "two prompts",
+			prompts: []*Prompt{text("a", "{{> b}}"), text("b", "{{> a}}")},
+			want:    map[string]string{"a": "include cycle", "b": "include cycle"},
+		},
+		{
+			name: "prompt outside the cycle reports only the cycle",
+			prompts: []*Prompt{
+				text("host", "{{> a}}"),
+				text("a", "{{> b}}"),
+				text("b", "{{> a}}"),
+			},
+			want:    map[string]string{"host": "include cycle", "a": "include cycle", "b": "include cycle"},
+			outside: "host",
+		},
+		{
+			name:    "escaped include is not followed",
+			prompts: []*Prompt{text("a", `\{{> a}}`)},
+			want:    map[string]string{},
+		},
+		{
+			name:    "diamond is not a cycle",
+			prompts: []*Prompt{text("a", "{{> b}} {{> c}}"), text("b", "{{> d}}"), text("c", "{{> d}}"), text("d", "x")},
+			want:    map[string]string{},
+		},
+		{
+			name:    "unknown include",
+			prompts: []*Prompt{text("a", "{{> missing}}")},
+			want:    map[string]string{"a": "unknown include: missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts := make(map[string]*Prompt, len(tt.prompts))
+			for _, p := range tt.prompts {
+				prompts[p.Name] = p
+			}
+
+			failed := resolveIncludes(prompts)
+			if len(failed) != len(tt.want) {
+				t.Fatalf("resolveIncludes() failed = %v, want %d failures", failed, len(tt.want))
+			}
+			for name, want := range tt.want {
+				if err := failed[name]; err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("error for %s = %v, want containing %q", name, err, want)
+				}
+			}
+			if err := failed[tt.outside]; err != nil && tt.outside != "" && strings.Contains(err.Error(), tt.outside) {
+				t.Errorf("error for %s = %v, want only the cycle", tt.outside, err)
+			}
+		})
+	}
+}
@@ -2,123 +2,387 @@ package prompt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/fs"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
-	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
 )
 
 // Manager 管理所有prompt模板
 type Manager struct {
-	promptsDir string
-	prompts    map[string]*Prompt
-	mutex      sync.RWMutex
-	watcher    *fsnotify.Watcher
+	sources []PromptSource
+	prompts map[string]*Prompt
+	mutex   sync.RWMutex
+	origins map[string]Origin
+	strict  bool
+	env     Environment
+	index   *searchIndex
+
+	reloadPolicy string
+	loadMutex    sync.Mutex
+	// 最近一次加载产生的诊断
+	diagnostics []Diagnostic
+
+	// 监控与加载相互独立，每个来源同一时刻最多只有一个监控
+	watching   bool
+	watchStops []func() error
+	watchMutex sync.Mutex
+
+	listeners     []func()
+	listenerMutex sync.Mutex
 }
 
-// NewManager 创建新的prompt管理器
-func NewManager(promptsDir string) *Manager {
+// NewManager 创建新的prompt管理器，sources按优先级从低到高排列
+func NewManager(sources ...PromptSource) *Manager {
 	return &Manager{
-		promptsDir: promptsDir,
-		prompts:    make(map[string]*Prompt),
+		sources: sources,
+		prompts: make(map[string]*Prompt),
+		origins: make(map[string]Origin),
+
+		reloadPolicy: ReloadPolicyReplace,
 	}
 }
 
-// LoadPrompts 加载所有prompt文件
-func (m *Manager) LoadPrompts() error {
+// SetStrict 设置是否对所有prompt启用严格模式，在下次加载时生效
+func (m *Manager) SetStrict(strict bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.strict = strict
+}
+
+// SetEnvironment 设置生成内置变量所用的环境信息
+func (m *Manager) SetEnvironment(env Environment) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.env = env
+}
+
+// BuiltinVariables 生成当前时刻的内置变量
+func (m *Manager) BuiltinVariables(clientName, clientVersion string) map[string]interface{} {
+	m.mutex.RLock()
+	env := m.env
+	m.mutex.RUnlock()
+
+	return env.Variables(clientName, clientVersion)
+}
+
+// SetReloadPolicy 设置重新加载时文件出错的处理方式，在下次加载时生效
+func (m *Manager) SetReloadPolicy(policy string) error {
+	if policy != ReloadPolicyReplace && policy != ReloadPolicyKeepLastGood {
+		return fmt.Errorf("unknown reload policy: %s", policy)
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// 清空现有prompts
-	m.prompts = make(map[string]*Prompt)
+	m.reloadPolicy = policy
+	return nil
+}
+
+// LoadPrompts 加载所有prompt文件，完成后通知所有重新加载回调，并返回与上次加载相比的变化。
+// 加载不会启动文件监控，需要热重载时调用StartWatching
+func (m *Manager) LoadPrompts() (*ReloadDiff, error) {
+	diff, err := m.loadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notifyReload()
+	return diff, nil
+}
+
+// OnReload 注册prompt重新加载后的回调
+func (m *Manager) OnReload(listener func()) {
+	m.listenerMutex.Lock()
+	defer m.listenerMutex.Unlock()
+
+	m.listeners = append(m.listeners, listener)
+}
+
+// notifyReload 依次调用所有重新加载回调
+func (m *Manager) notifyReload() {
+	m.listenerMutex.Lock()
+	listeners := make([]func(), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.listenerMutex.Unlock()
 
-	// 遍历prompts目录
-	err := filepath.WalkDir(m.promptsDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// loadAll 在不持有读写锁的情况下构建新的prompt集合，完成后原子替换当前集合。
+// 遍历目录失败时保持当前集合不变
+func (m *Manager) loadAll() (*ReloadDiff, error) {
+	// 同一时刻只进行一次加载，加载期间读取方仍看到完整的旧集合
+	m.loadMutex.Lock()
+	defer m.loadMutex.Unlock()
+
+	m.mutex.RLock()
+	strict := m.strict
+	policy := m.reloadPolicy
+	m.mutex.RUnlock()
+
+	// 先解析所有文件，引用展开需要完整的prompt集合
+	candidates := make(map[string]*Prompt)
+	origins := make(map[string]Origin)
+	// 本次加载出错的文件，用于保留其中prompt的旧版本
+	failedFiles := make(map[string]bool)
+	var diagnostics []Diagnostic
+	report := func(d *Diagnostic) {
+		log.Printf("Warning: %v", d)
+		diagnostics = append(diagnostics, *d)
+		if d.Kind != DiagnosticCollision {
+			failedFiles[d.File] = true
 		}
+	}
 
-		// 跳过目录
-		if d.IsDir() {
-			return nil
+	// 按顺序遍历各来源目录，后面的来源覆盖前面的同名prompt
+	for layer, source := range m.sources {
+		if err := m.loadSource(layer, source, candidates, origins, report); err != nil {
+			return nil, fmt.Errorf("failed to list %s prompts source: %w", source.Name(), err)
 		}
+	}
 
-		// 只处理yaml和json文件
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
-			return nil
+	// 合并extends继承关系，再展开 {{> name}} 引用
+	extendsErrors := resolveExtends(candidates)
+	includeErrors := resolveIncludes(candidates)
+
+	prompts := make(map[string]*Prompt)
+	promptOrigins := make(map[string]Origin)
+	partials := 0
+	for name, prompt := range candidates {
+		path := origins[name].Path
+
+		if err, failed := extendsErrors[name]; failed {
+			report(promptDiagnostic(path, DiagnosticExtends, prompt, err))
+			continue
 		}
 
-		// 加载prompt文件
-		prompt, err := m.loadPromptFile(path)
-		if err != nil {
-			log.Printf("Warning: Failed to load prompt file %s: %v", path, err)
-			return nil // 继续处理其他文件
+		if err, failed := includeErrors[name]; failed {
+			report(promptDiagnostic(path, DiagnosticInclude, prompt, err))
+			continue
 		}
 
 		// 验证prompt
 		if err := prompt.Validate(); err != nil {
-			log.Printf("Warning: Invalid prompt in file %s: %v", path, err)
-			return nil
+			report(promptDiagnostic(path, DiagnosticValidation, prompt, err))
+			continue
 		}
 
-		// 检查name冲突
-		if _, exists := m.prompts[prompt.Name]; exists {
-			log.Printf("Warning: Duplicate prompt name '%s' in file %s, skipping", prompt.Name, path)
-			return nil
+		// partial只用于引用，不加入prompt集合
+		if prompt.Partial {
+			partials++
+			continue
 		}
 
-		// 添加到管理器
-		m.prompts[prompt.Name] = prompt
+		if strict {
+			prompt.Strict = true
+		}
 
-		return nil
-	})
+		// 预编译模板
+		if err := prompt.Compile(); err != nil {
+			report(promptDiagnostic(path, DiagnosticTemplate, prompt, err))
+			continue
+		}
+
+		// 添加到新集合
+		prompts[name] = prompt
+		promptOrigins[name] = origins[name]
+	}
+
+	m.mutex.RLock()
+	previous, previousOrigins := m.prompts, m.origins
+	m.mutex.RUnlock()
+
+	// 文件出错时保留其中prompt上一次成功加载的版本，即使较早的来源仍提供同名prompt
+	var kept []string
+	if policy == ReloadPolicyKeepLastGood {
+		for name, prompt := range previous {
+			origin := previousOrigins[name]
+			if !failedFiles[origin.Path] {
+				continue
+			}
+			if current, exists := promptOrigins[name]; exists && current.layer >= origin.layer {
+				continue
+			}
+			prompts[name] = prompt
+			promptOrigins[name] = origin
+			kept = append(kept, name)
+		}
+	}
 
+	diff := diffPrompts(previous, prompts)
+	diff.Kept = sortedNames(kept)
+	index := buildSearchIndex(prompts)
+	sortDiagnostics(diagnostics)
+
+	// 原子替换
+	m.mutex.Lock()
+	m.prompts = prompts
+	m.origins = promptOrigins
+	m.index = index
+	m.diagnostics = diagnostics
+	m.mutex.Unlock()
+
+	log.Printf("Successfully loaded %d prompts (%d partials) from %d sources: %s", len(prompts), partials, len(m.sources), diff)
+	return diff, nil
+}
+
+// loadSource 解析一个来源中的所有prompt文件并加入候选集合。
+// 同一来源内名称重复视为冲突，覆盖较早来源的同名prompt则记录被覆盖的来源
+func (m *Manager) loadSource(layer int, source PromptSource, candidates map[string]*Prompt, origins map[string]Origin, report func(*Diagnostic)) error {
+	files, err := source.List()
 	if err != nil {
-		return fmt.Errorf("failed to walk prompts directory: %w", err)
+		return err
 	}
 
-	log.Printf("Successfully loaded %d prompts from %s", len(m.prompts), m.promptsDir)
+	defined := make(map[string]string)
+	overrides := 0
+
+	for _, rel := range files {
+		path := sourcePath(source, rel)
+
+		// 加载prompt文件
+		prompt, diag := m.loadPromptFile(source, rel, path)
+		if diag != nil {
+			report(diag)
+			continue // 继续处理其他文件
+		}
+
+		if prompt.Name == "" {
+			report(&Diagnostic{
+				File:    path,
+				Line:    prompt.positions.name.line,
+				Column:  prompt.positions.name.column,
+				Kind:    DiagnosticValidation,
+				Message: "prompt name cannot be empty",
+			})
+			continue
+		}
+
+		// 子目录作为命名空间，名称加上目录前缀
+		prompt.Namespace = namespaceOf(rel)
+		prompt.Name = qualifyName(prompt.Namespace, prompt.Name)
+
+		// 检查同一来源内的name冲突，保留先加载的文件
+		if existing, exists := defined[prompt.Name]; exists {
+			report(&Diagnostic{
+				File:    path,
+				Line:    prompt.positions.name.line,
+				Column:  prompt.positions.name.column,
+				Kind:    DiagnosticCollision,
+				Prompt:  prompt.Name,
+				Message: fmt.Sprintf("prompt name is already defined in %s, skipping this file", existing),
+			})
+			continue
+		}
+		defined[prompt.Name] = path
+
+		origin := Origin{Source: source.Name(), Path: path, layer: layer}
+		if previous, exists := origins[prompt.Name]; exists {
+			origin.Overridden = append(append([]string{}, previous.Overridden...), previous.Source)
+			overrides++
+		}
 
-	// 启动文件监控
-	if err := m.startWatching(); err != nil {
-		log.Printf("Warning: Failed to start file watching: %v", err)
+		candidates[prompt.Name] = prompt
+		origins[prompt.Name] = origin
 	}
 
+	if overrides > 0 {
+		log.Printf("%d prompts from %s source override earlier sources", overrides, source.Name())
+	}
 	return nil
 }
 
-// loadPromptFile 加载单个prompt文件
-func (m *Manager) loadPromptFile(filePath string) (*Prompt, error) {
-	data, err := os.ReadFile(filePath)
+// loadPromptFile 从来源中加载单个prompt文件，filePath为诊断中显示的路径，
+// 失败时返回带有出错位置的诊断
+func (m *Manager) loadPromptFile(source PromptSource, rel, filePath string) (*Prompt, *Diagnostic) {
+	data, err := source.Read(rel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, &Diagnostic{File: filePath, Kind: DiagnosticRead, Message: err.Error()}
 	}
 
 	var prompt Prompt
 
-	// 根据文件扩展名选择解析方式
+	// 根据文件扩展名选择解析方式，两种格式都解析为YAML节点树以记录字段位置
+	var root yaml.Node
 	ext := strings.ToLower(filepath.Ext(filePath))
 	if ext == ".json" {
 		if err := json.Unmarshal(data, &prompt); err != nil {
-			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+			return nil, jsonDiagnostic(filePath, data, err)
+		}
+		// JSON是YAML的子集，节点树解析失败时只是没有位置信息
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			root = yaml.Node{}
 		}
 	} else {
 		// 默认使用YAML解析
-		if err := yaml.Unmarshal(data, &prompt); err != nil {
-			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, yamlDiagnostic(filePath, data, nil, err)
+		}
+		// 空文件没有节点
+		if root.Kind != 0 {
+			if err := root.Decode(&prompt); err != nil {
+				return nil, yamlDiagnostic(filePath, data, &root, err)
+			}
 		}
 	}
 
+	prompt.locate(filePath, &root)
 	return &prompt, nil
 }
 
+// promptDiagnostic 创建prompt的诊断，位置取自错误所附的位置。错误没有位置时指向name字段；
+// 位置在其他文件中（继承或引用而来的内容）时指向extends字段，没有extends时指向name字段
+func promptDiagnostic(file, kind string, p *Prompt, err error) *Diagnostic {
+	d := &Diagnostic{File: file, Kind: kind, Prompt: p.Name, Message: err.Error()}
+
+	pos := p.positions.name
+	var posErr *positionError
+	if errors.As(err, &posErr) {
+		switch {
+		case posErr.pos.file == file:
+			pos = posErr.pos
+		case p.positions.extends.line > 0:
+			pos = p.positions.extends
+		}
+	}
+
+	d.Line, d.Column = pos.line, pos.column
+	return d
+}
+
+// Diagnostics 返回最近一次加载产生的诊断，按文件和位置排序
+func (m *Manager) Diagnostics() []Diagnostic {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	diagnostics := make([]Diagnostic, len(m.diagnostics))
+	copy(diagnostics, m.diagnostics)
+	return diagnostics
+}
+
+// GetOrigin 返回prompt的来源信息
+func (m *Manager) GetOrigin(name string) (Origin, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	origin, exists := m.origins[name]
+	return origin, exists
+}
+
+// Sources 返回按优先级从低到高排列的来源
+func (m *Manager) Sources() []PromptSource {
+	return append([]PromptSource{}, m.sources...)
+}
+
 // GetPrompts 获取所有prompts
 func (m *Manager) GetPrompts() []*Prompt {
 	m.mutex.RLock()
@@ -141,6 +405,18 @@ func (m *Manager) GetPrompt(name string) (*Prompt, bool) {
 	return prompt, exists
 }
 
+// Search 按相关度搜索prompts，匹配名称、描述、标签、分类和参数说明，limit<=0时返回全部匹配结果
+func (m *Manager) Search(query string, limit int, filter Filter) []SearchResult {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.index == nil {
+		return nil
+	}
+
+	return m.index.search(query, limit, filter)
+}
+
 // GetPromptNames 获取所有prompt名称
 func (m *Manager) GetPromptNames() []string {
 	m.mutex.RLock()
@@ -154,71 +430,30 @@ func (m *Manager) GetPromptNames() []string {
 	return names
 }
 
-// startWatching 启动文件监控，支持热重载
-func (m *Manager) startWatching() error {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("failed to create file watcher: %w", err)
-	}
-
-	m.watcher = watcher
-
-	// 添加prompts目录到监控
-	if err := watcher.Add(m.promptsDir); err != nil {
-		return fmt.Errorf("failed to watch prompts directory: %w", err)
-	}
-
-	// 启动监控goroutine
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-
-				// 只处理写入和创建事件
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-					// 检查是否是支持的文件类型
-					ext := strings.ToLower(filepath.Ext(event.Name))
-					if ext == ".yaml" || ext == ".yml" || ext == ".json" {
-						log.Printf("Detected change in %s, reloading prompts...", event.Name)
-						if err := m.LoadPrompts(); err != nil {
-							log.Printf("Failed to reload prompts: %v", err)
-						}
-					}
-				}
-
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Printf("File watcher error: %v", err)
-			}
-		}
-	}()
-
-	log.Printf("Started file watching for %s", m.promptsDir)
-	return nil
-}
-
-// Close 关闭管理器，清理资源
+// Close 关闭管理器，停止文件监控
 func (m *Manager) Close() error {
-	if m.watcher != nil {
-		return m.watcher.Close()
-	}
-	return nil
+	return m.StopWatching()
 }
 
 // Stats 获取统计信息
 func (m *Manager) Stats() map[string]interface{} {
+	// 先于m.mutex读取，避免与等待重新加载结束的StopWatching互相等待
+	watching := m.IsWatching()
+
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
+	sources := make(map[string]string, len(m.sources))
+	for _, source := range m.sources {
+		sources[source.Name()] = source.Location()
+	}
+
 	stats := map[string]interface{}{
 		"total_prompts":  len(m.prompts),
-		"prompts_dir":    m.promptsDir,
-		"watching_files": m.watcher != nil,
+		"sources":        sources,
+		"watching_files": watching,
+		"reload_policy":  m.reloadPolicy,
+		"load_errors":    len(m.diagnostics),
 	}
 
 	// 按类型统计
@@ -229,5 +464,29 @@ func (m *Manager) Stats() map[string]interface{} {
 	}
 	stats["argument_distribution"] = argumentCounts
 
+	// 按来源统计
+	sourceCounts := make(map[string]int)
+	for _, origin := range m.origins {
+		sourceCounts[origin.Source]++
+	}
+	stats["source_distribution"] = sourceCounts
+
+	// 按分类和标签统计
+	categoryCounts := make(map[string]int)
+	tagCounts := make(map[string]int)
+	for _, prompt := range m.prompts {
+		category := prompt.Category
+		if category == "" {
+			category = "uncategorized"
+		}
+		categoryCounts[category]++
+
+		for _, tag := range prompt.Tags {
+			tagCounts[tag]++
+		}
+	}
+	stats["category_distribution"] = categoryCounts
+	stats["tag_distribution"] = tagCounts
+
 	return stats
 }
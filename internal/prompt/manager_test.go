@@ -0,0 +1,362 @@
+package prompt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// promptFile 生成只有一条用户消息的prompt文件
+func promptFile(name, text string) []byte {
+	return []byte(fmt.Sprintf("name: %s\nmessages:\n  - role: user\n    content:\n      type: text\n      text: %q\n", name, text))
+}
+
+// promptText 返回已加载prompt的第一条消息
+func promptText(t *testing.T, m *Manager, name string) string {
+	t.Helper()
+
+	p, exists := m.GetPrompt(name)
+	if !exists {
+		t.Fatalf("prompt %s not loaded", name)
+	}
+	return p.Messages[0].Content.Text
+}
+
+func TestManagerLayeredSources(t *testing.T) {
+	builtin := NewMemorySource(SourceBuiltin)
+	builtin.Set("shared.yaml", promptFile("shared", "builtin"))
+	builtin.Set("only_builtin.yaml", promptFile("only_builtin", "builtin"))
+
+	user := NewMemorySource(SourceUser)
+	user.Set("shared.yaml", promptFile("shared", "user"))
+	user.Set("frontend/react.yaml", promptFile("react", "user"))
+
+	project := NewMemorySource(SourceProject)
+	project.Set("renamed.yaml", promptFile("shared", "project"))
+	project.Set("dup_a.yaml", promptFile("dup", "first"))
+	project.Set("dup_b.yaml", promptFile("dup", "second"))
+
+	m := NewManager(builtin, user, project)
+	diff, err := m.LoadPrompts()
+	if err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+
+	wantNames := []string{"dup", "frontend.react", "only_builtin", "shared"}
+	got := m.GetPromptNames()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("GetPromptNames() = %q, want %q", got, wantNames)
+	}
+	if !reflect.DeepEqual(diff.Added, wantNames) {
+		t.Errorf("diff.Added = %q, want %q", diff.Added, wantNames)
+	}
+
+	tests := []struct {
+		name       string
+		text       string
+		source     string
+		path       string
+		overridden []string
+	}{
+		{name: "shared", text: "project", source: SourceProject, path: "memory:project/renamed.yaml", overridden: []string{SourceBuiltin, SourceUser}},
+		{name: "only_builtin", text: "builtin", source: SourceBuiltin, path: "memory:builtin/only_builtin.yaml"},
+		{name: "frontend.react", text: "user", source: SourceUser, path: "memory:user/frontend/react.yaml"},
+		{name: "dup", text: "first", source: SourceProject, path: "memory:project/dup_a.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promptText(t, m, tt.name); got != tt.text {
+				t.Errorf("text = %q, want %q", got, tt.text)
+			}
+
+			origin, _ := m.GetOrigin(tt.name)
+			if origin.Source != tt.source || origin.Path != tt.path || !reflect.DeepEqual(origin.Overridden, tt.overridden) {
+				t.Errorf("GetOrigin() = %+v, want source %s, path %s, overridden %q", origin, tt.source, tt.path, tt.overridden)
+			}
+		})
+	}
+
+	// 同一来源内的重名是冲突，不同来源之间的同名是覆盖
+	diagnostics := m.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Kind != DiagnosticCollision || diagnostics[0].File != "memory:project/dup_b.yaml" {
+		t.Errorf("Diagnostics() = %+v, want one collision for dup_b.yaml", diagnostics)
+	}
+}
+
+func TestManagerReloadPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		want     ReloadDiff
+		wantText map[string]string
+		missing  []string
+	}{
+		{
+			name:   "replace",
+			policy: ReloadPolicyReplace,
+			want: ReloadDiff{
+				Added:   []string{"added"},
+				Removed: []string{"broken"},
+				Changed: []string{"changed", "overridden"},
+			},
+			wantText: map[string]string{"changed": "v2", "overridden": "builtin", "stable": "v1"},
+			missing:  []string{"broken"},
+		},
+		{
+			name:   "keep last good",
+			policy: ReloadPolicyKeepLastGood,
+			want: ReloadDiff{
+				Added:   []string{"added"},
+				Changed: []string{"changed"},
+				Kept:    []string{"broken", "overridden"},
+			},
+			wantText: map[string]string{"broken": "v1", "changed": "v2", "overridden": "project v1", "stable": "v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builtin := NewMemorySource(SourceBuiltin)
+			builtin.Set("overridden.yaml", promptFile("overridden", "builtin"))
+
+			project := NewMemorySource(SourceProject)
+			project.Set("stable.yaml", promptFile("stable", "v1"))
+			project.Set("changed.yaml", promptFile("changed", "v1"))
+			project.Set("broken.yaml", promptFile("broken", "v1"))
+			project.Set("overridden.yaml", promptFile("overridden", "project v1"))
+
+			m := NewManager(builtin, project)
+			if err := m.SetReloadPolicy(tt.policy); err != nil {
+				t.Fatalf("SetReloadPolicy() error = %v", err)
+			}
+			if _, err := m.LoadPrompts(); err != nil {
+				t.Fatalf("LoadPrompts() error = %v", err)
+			}
+
+			project.Set("changed.yaml", promptFile("changed", "v2"))
+			project.Set("broken.yaml", []byte("name: broken\nmessages: [\n"))
+			project.Set("overridden.yaml", []byte("name: overridden\nmessages: {\n"))
+			project.Set("added.yaml", promptFile("added", "v1"))
+
+			diff, err := m.LoadPrompts()
+			if err != nil {
+				t.Fatalf("LoadPrompts() error = %v", err)
+			}
+			if !reflect.DeepEqual(*diff, tt.want) {
+				t.Errorf("LoadPrompts() diff = %+v, want %+v", *diff, tt.want)
+			}
+
+			for name, want := range tt.wantText {
+				if got := promptText(t, m, name); got != want {
+					t.Errorf("%s text = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range tt.missing {
+				if _, exists := m.GetPrompt(name); exists {
+					t.Errorf("prompt %s still loaded", name)
+				}
+			}
+
+			// 两种策略下出错的文件都会出现在诊断中
+			if got := len(m.Diagnostics()); got != 2 {
+				t.Errorf("Diagnostics() has %d entries, want 2: %+v", got, m.Diagnostics())
+			}
+		})
+	}
+}
+
+func TestManagerDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		data   string
+		base   string // 可选的base.yaml，供继承使用
+		kind   string
+		prompt string
+		line   int
+		column int
+	}{
+		{
+			name:   "yaml syntax",
+			file:   "bad.yaml",
+			data:   "name: bad\nmessages:\n  - role: user\n   content: x\n",
+			kind:   DiagnosticParse,
+			line:   2,
+			column: 1,
+		},
+		{
+			name:   "yaml type",
+			file:   "typed.yaml",
+			data:   string(promptFile("typed", "x")) + "arguments:\n  - name: n\n    required: maybe\n",
+			kind:   DiagnosticParse,
+			line:   9,
+			column: 15,
+		},
+		{
+			name:   "yaml sequence type",
+			file:   "seq.yaml",
+			data:   "name: [a, b]\n",
+			kind:   DiagnosticParse,
+			line:   1,
+			column: 7,
+		},
+		{
+			name:   "json syntax",
+			file:   "bad.json",
+			data:   "{\n  \"name\": \"bad\",\n  \"messages\": [\n}\n",
+			kind:   DiagnosticParse,
+			line:   4,
+			column: 1,
+		},
+		{
+			name:   "collision",
+			file:   "zz_good.yaml",
+			data:   string(promptFile("good", "again")),
+			kind:   DiagnosticCollision,
+			prompt: "good",
+			line:   1,
+			column: 7,
+		},
+		{
+			name:   "unknown parent",
+			file:   "child.yaml",
+			data:   "name: child\nextends: missing\n",
+			kind:   DiagnosticExtends,
+			prompt: "child",
+			line:   2,
+			column: 10,
+		},
+		{
+			name:   "unknown include",
+			file:   "host.yaml",
+			data:   string(promptFile("host", "{{> missing}}")),
+			kind:   DiagnosticInclude,
+			prompt: "host",
+			line:   6,
+			column: 13,
+		},
+		{
+			name:   "invalid argument",
+			file:   "args.yaml",
+			data:   string(promptFile("args", "x")) + "arguments:\n  - name: n\n    type: date\n",
+			kind:   DiagnosticValidation,
+			prompt: "args",
+			line:   8,
+			column: 5,
+		},
+		{
+			name:   "invalid message",
+			file:   "role.yaml",
+			data:   string(promptFile("role", "x")) + "  - role: robot\n    content: {type: text, text: y}\n",
+			kind:   DiagnosticValidation,
+			prompt: "role",
+			line:   7,
+			column: 5,
+		},
+		{
+			name:   "invalid json message",
+			file:   "role.json",
+			data:   "{\n  \"name\": \"role\",\n  \"messages\": [\n    {\"role\": \"robot\", \"content\": {\"type\": \"text\", \"text\": \"x\"}}\n  ]\n}\n",
+			kind:   DiagnosticValidation,
+			prompt: "role",
+			line:   4,
+			column: 5,
+		},
+		{
+			name:   "prompt level error points at name",
+			file:   "partial.yaml",
+			data:   "partial: true\nname: partial\n",
+			kind:   DiagnosticValidation,
+			prompt: "partial",
+			line:   2,
+			column: 7,
+		},
+		{
+			name:   "template syntax",
+			file:   "tmpl.yaml",
+			data:   string(promptFile("tmpl", "{{ if .x }}")) + "engine: gotemplate\n",
+			kind:   DiagnosticTemplate,
+			prompt: "tmpl",
+			line:   6,
+			column: 13,
+		},
+		{
+			name:   "inherited message points at extends",
+			file:   "child.yaml",
+			data:   "name: child\nengine: gotemplate\nextends: base\n",
+			base:   string(promptFile("base", "{{ if .x }}")),
+			kind:   DiagnosticTemplate,
+			prompt: "child",
+			line:   3,
+			column: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewMemorySource(SourceProject)
+			source.Set("good.yaml", promptFile("good", "ok"))
+			source.Set(tt.file, []byte(tt.data))
+			if tt.base != "" {
+				source.Set("base.yaml", []byte(tt.base))
+			}
+
+			m := NewManager(source)
+			if _, err := m.LoadPrompts(); err != nil {
+				t.Fatalf("LoadPrompts() error = %v", err)
+			}
+
+			if _, exists := m.GetPrompt("good"); !exists {
+				t.Errorf("valid prompt not loaded next to a broken file")
+			}
+
+			diagnostics := m.Diagnostics()
+			if len(diagnostics) != 1 {
+				t.Fatalf("Diagnostics() = %+v, want exactly one", diagnostics)
+			}
+			d := diagnostics[0]
+			if d.Kind != tt.kind || d.Prompt != tt.prompt || d.File != "memory:project/"+tt.file {
+				t.Errorf("diagnostic = %+v, want kind %s, prompt %q, file %s", d, tt.kind, tt.prompt, tt.file)
+			}
+			if d.Line != tt.line || d.Column != tt.column {
+				t.Errorf("diagnostic %v at %d:%d, want %d:%d", &d, d.Line, d.Column, tt.line, tt.column)
+			}
+		})
+	}
+}
+
+func TestManagerWatchMemorySource(t *testing.T) {
+	source := NewMemorySource(SourceProject)
+	source.Set("a.yaml", promptFile("a", "v1"))
+
+	m := NewManager(source)
+	if _, err := m.LoadPrompts(); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+
+	reloads := 0
+	m.OnReload(func() { reloads++ })
+
+	if err := m.StartWatching(); err != nil {
+		t.Fatalf("StartWatching() error = %v", err)
+	}
+	source.Set("a.yaml", promptFile("a", "v2"))
+	if got := promptText(t, m, "a"); got != "v2" {
+		t.Errorf("text after Set = %q, want v2", got)
+	}
+
+	if err := m.StopWatching(); err != nil {
+		t.Fatalf("StopWatching() error = %v", err)
+	}
+	source.Remove("a.yaml")
+	if _, exists := m.GetPrompt("a"); !exists {
+		t.Errorf("prompt removed after StopWatching")
+	}
+
+	if reloads != 1 {
+		t.Errorf("reloads = %d, want 1", reloads)
+	}
+}
@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+)
+
+// MemorySource 保存在内存中的来源，适用于测试或由其他后端同步而来的prompt。
+// Set和Remove会同步调用监控回调
+type MemorySource struct {
+	name string
+
+	mutex     sync.RWMutex
+	files     map[string][]byte
+	listeners map[int]func()
+	nextID    int
+}
+
+// NewMemorySource 创建空的内存来源
+func NewMemorySource(name string) *MemorySource {
+	return &MemorySource{
+		name:      name,
+		files:     make(map[string][]byte),
+		listeners: make(map[int]func()),
+	}
+}
+
+// Name 来源名称
+func (s *MemorySource) Name() string {
+	return s.name
+}
+
+// Location 来源位置
+func (s *MemorySource) Location() string {
+	return "memory:" + s.name
+}
+
+// Set 添加或替换文件，路径以/分隔，扩展名决定按YAML还是JSON解析
+func (s *MemorySource) Set(name string, data []byte) {
+	s.mutex.Lock()
+	s.files[path.Clean(name)] = append([]byte(nil), data...)
+	s.mutex.Unlock()
+
+	s.notify()
+}
+
+// Remove 删除文件，文件不存在时不做任何事
+func (s *MemorySource) Remove(name string) {
+	s.mutex.Lock()
+	_, exists := s.files[path.Clean(name)]
+	delete(s.files, path.Clean(name))
+	s.mutex.Unlock()
+
+	if exists {
+		s.notify()
+	}
+}
+
+// List 列出所有prompt文件，按路径排序
+func (s *MemorySource) List() ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	files := make([]string, 0, len(s.files))
+	for name := range s.files {
+		if isPromptFile(name) {
+			files = append(files, name)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Read 读取文件内容
+func (s *MemorySource) Read(name string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	data, exists := s.files[path.Clean(name)]
+	if !exists {
+		return nil, fmt.Errorf("file not found: %s", name)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Watch 注册变化回调，返回的函数用于注销
+func (s *MemorySource) Watch(changed func()) (func() error, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.listeners[id] = changed
+
+	return func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		delete(s.listeners, id)
+		return nil
+	}, nil
+}
+
+// notify 依次调用变化回调
+func (s *MemorySource) notify() {
+	s.mutex.RLock()
+	listeners := make([]func(), 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		listeners = append(listeners, listener)
+	}
+	s.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
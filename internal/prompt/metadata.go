@@ -0,0 +1,125 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 元数据中日期字段的格式
+const metadataDateLayout = "2006-01-02"
+
+// Metadata prompt的可选元数据，内嵌在Prompt中，YAML中与name等字段平级
+type Metadata struct {
+	Tags       []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Category   string   `yaml:"category,omitempty" json:"category,omitempty"`
+	Author     string   `yaml:"author,omitempty" json:"author,omitempty"`
+	Version    string   `yaml:"version,omitempty" json:"version,omitempty"`
+	License    string   `yaml:"license,omitempty" json:"license,omitempty"`
+	Created    string   `yaml:"created,omitempty" json:"created,omitempty"`
+	Updated    string   `yaml:"updated,omitempty" json:"updated,omitempty"`
+	ModelHints []string `yaml:"modelHints,omitempty" json:"modelHints,omitempty"`
+}
+
+// Meta 返回非空的元数据字段，用于工具和prompt的 _meta；没有元数据时返回nil
+func (m *Metadata) Meta() map[string]interface{} {
+	meta := make(map[string]interface{})
+
+	if len(m.Tags) > 0 {
+		meta["tags"] = m.Tags
+	}
+	if m.Category != "" {
+		meta["category"] = m.Category
+	}
+	if m.Author != "" {
+		meta["author"] = m.Author
+	}
+	if m.Version != "" {
+		meta["version"] = m.Version
+	}
+	if m.License != "" {
+		meta["license"] = m.License
+	}
+	if m.Created != "" {
+		meta["created"] = m.Created
+	}
+	if m.Updated != "" {
+		meta["updated"] = m.Updated
+	}
+	if len(m.ModelHints) > 0 {
+		meta["modelHints"] = m.ModelHints
+	}
+
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// HasTag 判断是否包含指定标签，不区分大小写
+func (m *Metadata) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter 按元数据过滤prompt的条件，空字段表示不限制
+type Filter struct {
+	Tag      string
+	Category string
+}
+
+// Match 判断prompt是否满足过滤条件，标签和分类均不区分大小写
+func (f Filter) Match(p *Prompt) bool {
+	if f.Tag != "" && !p.HasTag(f.Tag) {
+		return false
+	}
+	if f.Category != "" && !strings.EqualFold(p.Category, f.Category) {
+		return false
+	}
+	return true
+}
+
+// validate 检查日期字段格式
+func (m *Metadata) validate() error {
+	for field, value := range map[string]string{"created": m.Created, "updated": m.Updated} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(metadataDateLayout, value); err != nil {
+			return fmt.Errorf("%s must be a date in YYYY-MM-DD format: %q", field, value)
+		}
+	}
+	return nil
+}
+
+// inherit 未设置的元数据字段沿用父prompt的值
+func (m *Metadata) inherit(parent *Metadata) {
+	if len(m.Tags) == 0 {
+		m.Tags = parent.Tags
+	}
+	if m.Category == "" {
+		m.Category = parent.Category
+	}
+	if m.Author == "" {
+		m.Author = parent.Author
+	}
+	if m.Version == "" {
+		m.Version = parent.Version
+	}
+	if m.License == "" {
+		m.License = parent.License
+	}
+	if m.Created == "" {
+		m.Created = parent.Created
+	}
+	if m.Updated == "" {
+		m.Updated = parent.Updated
+	}
+	if len(m.ModelHints) == 0 {
+		m.ModelHints = parent.ModelHints
+	}
+}
@@ -2,8 +2,8 @@ package prompt
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
+	"text/template"
 )
 
 // Prompt 表示一个prompt模板
@@ -12,6 +12,32 @@ type Prompt struct {
 	Description string     `yaml:"description" json:"description"`
 	Arguments   []Argument `yaml:"arguments" json:"arguments"`
 	Messages    []Message  `yaml:"messages" json:"messages"`
+
+	// 标签、分类、作者等可选元数据
+	Metadata `yaml:",inline"`
+
+	// Strict 为true时，渲染后仍有未替换的占位符视为错误
+	Strict bool `yaml:"strict,omitempty" json:"strict,omitempty"`
+
+	// Extends 父prompt名称，加载时与父prompt合并
+	Extends string `yaml:"extends,omitempty" json:"extends,omitempty"`
+
+	// Partial 为true时只作为可引用的片段，不会暴露为工具或prompt
+	Partial bool `yaml:"partial,omitempty" json:"partial,omitempty"`
+	// Text partial的正文，可代替messages使用
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
+
+	// Engine 模板引擎，默认为simple，设置为gotemplate启用条件、循环和过滤器
+	Engine string `yaml:"engine,omitempty" json:"engine,omitempty"`
+
+	// Namespace 由所在子目录决定的命名空间，如 frontend；加载后Name带有该前缀
+	Namespace string `yaml:"-" json:"-"`
+
+	// 由Compile生成的消息模板，与Messages一一对应
+	templates []*template.Template
+
+	// 各字段在源文件中的位置，用于诊断
+	positions promptPositions
 }
 
 // Argument 表示prompt的参数
@@ -20,12 +46,33 @@ type Argument struct {
 	Description string `yaml:"description" json:"description"`
 	Required    bool   `yaml:"required" json:"required"`
 	Type        string `yaml:"type" json:"type"`
+
+	// Default 参数省略时使用的默认值
+	Default interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// 可选约束
+	Enum      []interface{} `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Pattern   string        `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	MinLength *int          `yaml:"minLength,omitempty" json:"minLength,omitempty"`
+	MaxLength *int          `yaml:"maxLength,omitempty" json:"maxLength,omitempty"`
+	Minimum   *float64      `yaml:"minimum,omitempty" json:"minimum,omitempty"`
+	Maximum   *float64      `yaml:"maximum,omitempty" json:"maximum,omitempty"`
+	Items     *Argument     `yaml:"items,omitempty" json:"items,omitempty"`
+
+	// 参数定义在源文件中的位置
+	pos position
 }
 
 // Message 表示prompt的消息
 type Message struct {
+	// Name 可选的消息块名称，子prompt通过同名消息块覆盖父prompt中的内容
+	Name    string  `yaml:"name,omitempty" json:"name,omitempty"`
 	Role    string  `yaml:"role" json:"role"`
 	Content Content `yaml:"content" json:"content"`
+
+	// 消息及其正文在源文件中的位置
+	pos     position
+	textPos position
 }
 
 // Content 表示消息内容
@@ -34,43 +81,115 @@ type Content struct {
 	Text string `yaml:"text" json:"text"`
 }
 
-// Execute 执行prompt，替换参数并返回最终内容
-func (p *Prompt) Execute(args map[string]interface{}) (string, error) {
-	var result strings.Builder
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Execute 执行prompt，校验并转换参数后替换占位符，按原有顺序返回渲染后的所有消息。
+// builtins为内置变量，合并到保留的ctx命名空间下，不会与用户参数冲突
+func (p *Prompt) Execute(args map[string]interface{}, builtins map[string]interface{}) ([]Message, error) {
+	args, err := p.CoerceArguments(args)
+	if err != nil {
+		return nil, err
+	}
 
-	// 处理所有用户消息
-	for _, message := range p.Messages {
-		if message.Role == "user" && message.Content.Type == "text" {
-			content := message.Content.Text
+	if builtins != nil {
+		args[BuiltinNamespace] = builtins
+	} else {
+		delete(args, BuiltinNamespace)
+	}
+
+	var (
+		messages   []Message
+		unresolved []string
+	)
 
+	for i, message := range p.Messages {
+		if message.Content.Type != "text" {
+			continue
+		}
+
+		var content string
+		if p.Engine == EngineGoTemplate {
+			content, err = p.renderTemplate(i, args)
+			if err != nil {
+				return nil, err
+			}
+		} else {
 			// 替换参数占位符 {{param}}
-			content = p.replaceParameters(content, args)
+			var missing []string
+			content, missing = p.replaceParameters(message.Content.Text, args)
+			unresolved = append(unresolved, missing...)
+		}
 
-			result.WriteString(content)
-			result.WriteString("\n\n")
+		messages = append(messages, Message{
+			Role: message.Role,
+			Content: Content{
+				Type: "text",
+				Text: strings.TrimSpace(content),
+			},
+		})
+	}
+
+	// 严格模式下不允许残留占位符
+	if p.Strict && len(unresolved) > 0 {
+		var errs ArgumentErrors
+		seen := make(map[string]bool)
+		for _, name := range unresolved {
+			if !seen[name] {
+				seen[name] = true
+				errs = append(errs, ArgumentError{Name: name, Message: "unresolved placeholder"})
+			}
 		}
+		return nil, errs
 	}
 
-	return strings.TrimSpace(result.String()), nil
+	return messages, nil
 }
 
-// replaceParameters 替换内容中的参数占位符
-func (p *Prompt) replaceParameters(content string, args map[string]interface{}) string {
-	// 匹配 {{param}} 格式的占位符
-	re := regexp.MustCompile(`\{\{(\w+)\}\}`)
+// CoerceArguments 按参数声明填充默认值、校验并转换参数值，返回所有不合法参数的ArgumentErrors。
+// 未声明的参数原样保留
+func (p *Prompt) CoerceArguments(args map[string]interface{}) (map[string]interface{}, error) {
+	coerced := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		coerced[name] = value
+	}
 
-	return re.ReplaceAllStringFunc(content, func(match string) string {
-		// 提取参数名
-		paramName := strings.Trim(match, "{}")
+	var errs ArgumentErrors
+	for i := range p.Arguments {
+		arg := &p.Arguments[i]
+		value, exists := args[arg.Name]
+		if (!exists || value == nil || value == "") && arg.Default != nil {
+			value, exists = arg.Default, true
+		}
+		if !exists || value == nil || value == "" {
+			if arg.Required {
+				errs = append(errs, ArgumentError{Name: arg.Name, Message: "missing required argument"})
+			}
+			continue
+		}
 
-		// 查找参数值
-		if value, exists := args[paramName]; exists {
-			return fmt.Sprintf("%v", value)
+		v, err := arg.Coerce(value)
+		if err != nil {
+			errs = append(errs, ArgumentError{Name: arg.Name, Message: err.Error()})
+			continue
 		}
+		coerced[arg.Name] = v
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return coerced, nil
+}
 
-		// 如果参数不存在，保持原样
-		return match
-	})
+// replaceParameters 替换内容中的参数占位符，同时返回未能替换的参数路径
+func (p *Prompt) replaceParameters(content string, args map[string]interface{}) (string, []string) {
+	return renderPlaceholders(content, args)
 }
 
 // Validate 验证prompt配置的有效性
@@ -79,21 +198,69 @@ func (p *Prompt) Validate() error {
 		return fmt.Errorf("prompt name cannot be empty")
 	}
 
+	if err := p.Metadata.validate(); err != nil {
+		return err
+	}
+
+	// partial只需要有可引用的内容
+	if p.Partial {
+		if p.Text == "" && len(p.Messages) == 0 {
+			return fmt.Errorf("partial must have text or messages")
+		}
+		return nil
+	}
+
 	if len(p.Messages) == 0 {
-		return fmt.Errorf("prompt must have at least one message")
+		return atPosition(p.positions.messages, fmt.Errorf("prompt must have at least one message"))
 	}
 
-	// 检查是否有用户消息
+	// 检查消息角色、内容类型和消息块名称，并确认有用户消息
 	hasUserMessage := false
-	for _, msg := range p.Messages {
-		if msg.Role == "user" {
+	blockNames := make(map[string]bool)
+	for i, msg := range p.Messages {
+		if msg.Name != "" {
+			if blockNames[msg.Name] {
+				return atPosition(msg.pos, fmt.Errorf("duplicate message block name: %s", msg.Name))
+			}
+			blockNames[msg.Name] = true
+		}
+
+		switch msg.Role {
+		case RoleUser:
 			hasUserMessage = true
-			break
+		case RoleSystem, RoleAssistant:
+		default:
+			return atPosition(msg.pos, fmt.Errorf("unsupported message role: %q", msg.Role))
+		}
+
+		// 缺少内容类型的消息在执行时会被跳过
+		if msg.Content.Type == "" {
+			return atPosition(msg.pos, fmt.Errorf("message %d: content type cannot be empty", i))
 		}
 	}
 
 	if !hasUserMessage {
-		return fmt.Errorf("prompt must have at least one user message")
+		return atPosition(p.positions.messages, fmt.Errorf("prompt must have at least one user message"))
+	}
+
+	// 检查参数定义
+	seen := make(map[string]bool, len(p.Arguments))
+	for i := range p.Arguments {
+		arg := &p.Arguments[i]
+		if arg.Name == "" {
+			return atPosition(arg.pos, fmt.Errorf("argument name cannot be empty"))
+		}
+		if arg.Name == BuiltinNamespace {
+			return atPosition(arg.pos, fmt.Errorf("argument name %q is reserved for built-in variables", BuiltinNamespace))
+		}
+		if seen[arg.Name] {
+			return atPosition(arg.pos, fmt.Errorf("duplicate argument name: %s", arg.Name))
+		}
+		seen[arg.Name] = true
+
+		if err := arg.validateDefinition(); err != nil {
+			return atPosition(arg.pos, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"path"
+	"strings"
+)
+
+// NamespaceSeparator prompt名称中命名空间的分隔符，prompts/frontend/a.yaml 中的
+// prompt a 的完整名称为 frontend.a
+const NamespaceSeparator = "."
+
+// namespaceOf 根据文件在来源中的相对路径（以/分隔）计算命名空间，根目录下的文件没有命名空间
+func namespaceOf(rel string) string {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return ""
+	}
+
+	return strings.ReplaceAll(dir, "/", NamespaceSeparator)
+}
+
+// qualifyName 为名称加上命名空间前缀
+func qualifyName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + NamespaceSeparator + name
+}
+
+// LocalName 返回不带命名空间前缀的名称
+func (p *Prompt) LocalName() string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return strings.TrimPrefix(p.Name, p.Namespace+NamespaceSeparator)
+}
+
+// ToolName 返回注册为工具时使用的名称，命名空间各级之间及与名称之间使用separator连接
+func (p *Prompt) ToolName(separator string) string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+
+	parts := append(strings.Split(p.Namespace, NamespaceSeparator), p.LocalName())
+	return strings.Join(parts, separator)
+}
+
+// lookupReference 按命名空间由内向外查找referrer中extends或include引用的prompt：
+// 在frontend.react中引用base时依次尝试frontend.react.base、frontend.base和base，并跳过referrer自身，
+// 因此frontend.code_review可以继承根目录的code_review。以分隔符开头的引用（如 .base）只查找根目录
+func lookupReference(prompts map[string]*Prompt, referrer, namespace, ref string) (string, bool) {
+	if strings.HasPrefix(ref, NamespaceSeparator) {
+		name := strings.TrimPrefix(ref, NamespaceSeparator)
+		_, exists := prompts[name]
+		return name, exists
+	}
+
+	for {
+		name := qualifyName(namespace, ref)
+		if _, exists := prompts[name]; exists && name != referrer {
+			return name, true
+		}
+
+		if namespace == "" {
+			return "", false
+		}
+
+		if i := strings.LastIndex(namespace, NamespaceSeparator); i >= 0 {
+			namespace = namespace[:i]
+		} else {
+			namespace = ""
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package prompt
+
+import "testing"
+
+func TestLookupReference(t *testing.T) {
+	prompts := map[string]*Prompt{
+		"base":                  {Name: "base"},
+		"code_review":           {Name: "code_review"},
+		"frontend.base":         {Name: "frontend.base"},
+		"frontend.code_review":  {Name: "frontend.code_review"},
+		"frontend.react.widget": {Name: "frontend.react.widget"},
+	}
+
+	tests := []struct {
+		name      string
+		referrer  string
+		namespace string
+		ref       string
+		want      string
+		wantFound bool
+	}{
+		{name: "inner namespace first", referrer: "frontend.react.widget", namespace: "frontend.react", ref: "base", want: "frontend.base", wantFound: true},
+		{name: "root from root", referrer: "code_review", ref: "base", want: "base", wantFound: true},
+		{name: "full name", referrer: "base", ref: "frontend.base", want: "frontend.base", wantFound: true},
+		{name: "skips the referrer itself", referrer: "frontend.code_review", namespace: "frontend", ref: "code_review", want: "code_review", wantFound: true},
+		{name: "rooted reference skips inner namespace", referrer: "frontend.react.widget", namespace: "frontend.react", ref: ".base", want: "base", wantFound: true},
+		{name: "rooted reference to a missing prompt", referrer: "frontend.base", namespace: "frontend", ref: ".widget", wantFound: false},
+		{name: "self reference at root is kept for cycle detection", referrer: "base", ref: ".base", want: "base", wantFound: true},
+		{name: "missing", referrer: "base", ref: "missing", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := lookupReference(prompts, tt.referrer, tt.namespace, tt.ref)
+			if found != tt.wantFound || (found && got != tt.want) {
+				t.Errorf("lookupReference() = %q, %v, want %q, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestManagerNamespacedVariantExtendsRoot(t *testing.T) {
+	source := NewMemorySource(SourceProject)
+	source.Set("code_review.yaml", promptFile("code_review", "root"))
+	source.Set("frontend/code_review.yaml", []byte("name: code_review\nextends: code_review\ndescription: frontend\n"))
+	source.Set("frontend/base.yaml", promptFile("base", "frontend base"))
+	source.Set("base.yaml", promptFile("base", "root base"))
+	source.Set("frontend/rooted.yaml", []byte("name: rooted\nextends: .base\n"))
+
+	m := NewManager(source)
+	if _, err := m.LoadPrompts(); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+	if diagnostics := m.Diagnostics(); len(diagnostics) > 0 {
+		t.Fatalf("Diagnostics() = %+v, want none", diagnostics)
+	}
+
+	if got := promptText(t, m, "frontend.code_review"); got != "root" {
+		t.Errorf("frontend.code_review text = %q, want inherited root", got)
+	}
+	if got := promptText(t, m, "frontend.rooted"); got != "root base" {
+		t.Errorf("frontend.rooted text = %q, want root base", got)
+	}
+}
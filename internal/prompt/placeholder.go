@@ -0,0 +1,164 @@
+package prompt
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 占位符语法（simple引擎）：
+//
+//	{{name}}          替换为参数值，name可以包含任意Unicode字母、数字和下划线
+//	{{user.name}}     沿点号路径访问对象参数的字段或数组参数的下标
+//	{{name|默认值}}    参数缺失或为空字符串时使用竖线后的默认值
+//	\{{               输出字面量 {{
+//
+// 不符合语法的 {{...}} 原样保留
+
+const (
+	openDelim   = "{{"
+	closeDelim  = "}}"
+	escapeDelim = `\{{`
+)
+
+// placeholder 解析后的占位符
+type placeholder struct {
+	path       []string
+	defaultVal string
+	hasDefault bool
+}
+
+// renderPlaceholders 替换内容中的占位符，返回替换结果和未能解析的参数路径
+func renderPlaceholders(content string, args map[string]interface{}) (string, []string) {
+	var (
+		out        strings.Builder
+		unresolved []string
+	)
+
+	for len(content) > 0 {
+		// 转义的左定界符
+		if strings.HasPrefix(content, escapeDelim) {
+			out.WriteString(openDelim)
+			content = content[len(escapeDelim):]
+			continue
+		}
+
+		if !strings.HasPrefix(content, openDelim) {
+			next := strings.IndexAny(content[1:], `\{`)
+			if next < 0 {
+				out.WriteString(content)
+				break
+			}
+			out.WriteString(content[:next+1])
+			content = content[next+1:]
+			continue
+		}
+
+		end := strings.Index(content[len(openDelim):], closeDelim)
+		if end < 0 {
+			out.WriteString(content)
+			break
+		}
+
+		inner := content[len(openDelim) : len(openDelim)+end]
+		token := content[:len(openDelim)+end+len(closeDelim)]
+
+		ph, ok := parsePlaceholder(inner)
+		if !ok {
+			// 不是合法的占位符，输出一个字符后继续扫描，使 {{{name}}} 中的占位符仍能被替换
+			out.WriteByte(content[0])
+			content = content[1:]
+			continue
+		}
+		content = content[len(token):]
+
+		// 与参数默认值一致，空字符串视为未提供
+		if value, exists := lookupPath(args, ph.path); exists && !(ph.hasDefault && value == "") {
+			out.WriteString(formatValue(value))
+			continue
+		}
+
+		if ph.hasDefault {
+			out.WriteString(ph.defaultVal)
+			continue
+		}
+
+		// 参数不存在时保持原样
+		unresolved = append(unresolved, strings.Join(ph.path, "."))
+		out.WriteString(token)
+	}
+
+	return out.String(), unresolved
+}
+
+// parsePlaceholder 解析定界符之间的内容：路径 [| 默认值]
+func parsePlaceholder(inner string) (placeholder, bool) {
+	var ph placeholder
+
+	expr := inner
+	if i := strings.Index(inner, "|"); i >= 0 {
+		expr = inner[:i]
+		ph.defaultVal = strings.TrimSpace(inner[i+1:])
+		ph.hasDefault = true
+	}
+
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return ph, false
+	}
+
+	for _, segment := range strings.Split(expr, ".") {
+		if !isIdentifier(segment) {
+			return ph, false
+		}
+		ph.path = append(ph.path, segment)
+	}
+
+	return ph, true
+}
+
+// isIdentifier 判断是否为由Unicode字母、数字和下划线组成的非空标识符
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r) {
+			return false
+		}
+		s = s[size:]
+	}
+
+	return true
+}
+
+// lookupPath 沿路径查找参数值，支持对象字段和数组下标
+func lookupPath(args map[string]interface{}, path []string) (interface{}, bool) {
+	value, exists := args[path[0]]
+	if !exists || value == nil {
+		return nil, false
+	}
+
+	for _, segment := range path[1:] {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value, exists = v[segment]
+			if !exists || value == nil {
+				return nil, false
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
@@ -0,0 +1,73 @@
+package prompt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderPlaceholders(t *testing.T) {
+	args := map[string]interface{}{
+		"name":  "Go",
+		"主题":    "并发",
+		"naïve": "yes",
+		"count": int64(3),
+		"user":  map[string]interface{}{"name": "Ann", "tags": []interface{}{"a", "b"}},
+		"empty": nil,
+		"语气":    "",
+	}
+
+	tests := []struct {
+		name           string
+		content        string
+		want           string
+		wantUnresolved []string
+	}{
+		{name: "simple", content: "hello {{name}}", want: "hello Go"},
+		{name: "spaces inside delimiters", content: "{{ name }}", want: "Go"},
+		{name: "number", content: "{{count}} items", want: "3 items"},
+		{name: "unicode name", content: "关于{{主题}}", want: "关于并发"},
+		{name: "combining mark", content: "{{naïve}}", want: "yes"},
+		{name: "escaped delimiter", content: `\{{name}} is {{name}}`, want: "{{name}} is Go"},
+		{name: "escape without placeholder", content: `a \{{ b`, want: "a {{ b"},
+		{name: "triple braces", content: "{{{name}}}", want: "{Go}"},
+		{name: "nested path", content: "{{user.name}}", want: "Ann"},
+		{name: "array index", content: "{{user.tags.1}}", want: "b"},
+		{name: "object value as json", content: "{{user.tags}}", want: `["a","b"]`},
+		{name: "default", content: "{{missing|fallback}}", want: "fallback"},
+		{name: "default with spaces", content: "{{ missing | 默认 值 }}", want: "默认 值"},
+		{name: "default keeps later bars", content: "{{missing|b|c}}", want: "b|c"},
+		{name: "present value ignores default", content: "{{name|b|c}}", want: "Go"},
+		{name: "empty default", content: "[{{missing|}}]", want: "[]"},
+		{name: "nil value uses default", content: "{{empty|none}}", want: "none"},
+		{name: "empty string uses default", content: "{{语气|正式}}", want: "正式"},
+		{name: "empty string without default", content: "[{{语气}}]", want: "[]"},
+		{
+			name:           "unresolved name",
+			content:        "{{missing}}",
+			want:           "{{missing}}",
+			wantUnresolved: []string{"missing"},
+		},
+		{
+			name:           "unresolved path",
+			content:        "{{user.email}} {{user.tags.5}} {{name.x}}",
+			want:           "{{user.email}} {{user.tags.5}} {{name.x}}",
+			wantUnresolved: []string{"user.email", "user.tags.5", "name.x"},
+		},
+		{name: "spaces around dots are not a placeholder", content: "{{ x . y }}", want: "{{ x . y }}"},
+		{name: "empty path", content: "{{ }} {{|x}}", want: "{{ }} {{|x}}"},
+		{name: "include syntax is not a placeholder", content: "{{> part}}", want: "{{> part}}"},
+		{name: "unterminated", content: "{{name", want: "{{name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved := renderPlaceholders(tt.content, args)
+			if got != tt.want {
+				t.Errorf("renderPlaceholders() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+				t.Errorf("unresolved = %q, want %q", unresolved, tt.wantUnresolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package prompt
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// position 源文件中的位置，line为0表示位置未知
+type position struct {
+	file   string
+	line   int
+	column int
+}
+
+// promptPositions prompt各字段在源文件中的位置，用于诊断
+type promptPositions struct {
+	name     position
+	extends  position
+	text     position
+	messages position
+}
+
+// positionError 带有出错位置的错误
+type positionError struct {
+	pos position
+	err error
+}
+
+// Error 返回原始错误信息
+func (e *positionError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *positionError) Unwrap() error {
+	return e.err
+}
+
+// atPosition 为错误附加出错位置，位置未知时原样返回
+func atPosition(pos position, err error) error {
+	if err == nil || pos.line == 0 {
+		return err
+	}
+	return &positionError{pos: pos, err: err}
+}
+
+// nodePosition 返回YAML节点的位置，节点为nil时位置未知
+func nodePosition(file string, node *yaml.Node) position {
+	if node == nil {
+		return position{}
+	}
+	return position{file: file, line: node.Line, column: node.Column}
+}
+
+// locate 根据文件的YAML节点树记录prompt字段、参数和消息的位置。
+// JSON是YAML的子集，JSON文件同样可以得到节点树
+func (p *Prompt) locate(file string, root *yaml.Node) {
+	node := resolveAlias(root)
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = resolveAlias(node.Content[0])
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		switch key.Value {
+		case "name":
+			p.positions.name = nodePosition(file, value)
+		case "extends":
+			p.positions.extends = nodePosition(file, value)
+		case "text":
+			p.positions.text = nodePosition(file, value)
+		case "messages":
+			p.positions.messages = nodePosition(file, key)
+			for j, item := range sequenceItems(value) {
+				if j >= len(p.Messages) {
+					break
+				}
+				p.Messages[j].pos = nodePosition(file, item)
+				p.Messages[j].textPos = nodePosition(file, mappingValue(mappingValue(item, "content"), "text"))
+			}
+		case "arguments":
+			for j, item := range sequenceItems(value) {
+				if j >= len(p.Arguments) {
+					break
+				}
+				p.Arguments[j].pos = nodePosition(file, item)
+			}
+		}
+	}
+}
+
+// resolveAlias 返回别名节点指向的节点
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// sequenceItems 返回序列节点的元素，非序列节点返回nil
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	items := make([]*yaml.Node, len(node.Content))
+	for i, item := range node.Content {
+		items[i] = resolveAlias(item)
+	}
+	return items
+}
+
+// mappingValue 返回映射节点中key对应的值节点，不存在时返回nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// nodeAtLine 按文档顺序返回位于指定行的第一个指定类型的节点，key表示是否查找映射的键，
+// 用于为只带行号的YAML解码错误补充列号
+func nodeAtLine(root *yaml.Node, line int, kind yaml.Kind, key bool) *yaml.Node {
+	var found *yaml.Node
+
+	var walk func(node *yaml.Node, isKey bool)
+	walk = func(node *yaml.Node, isKey bool) {
+		if node == nil || found != nil {
+			return
+		}
+		if node.Line == line && node.Kind == kind && isKey == key {
+			found = node
+			return
+		}
+		for i, child := range node.Content {
+			walk(child, node.Kind == yaml.MappingNode && i%2 == 0)
+		}
+	}
+
+	walk(root, false)
+	return found
+}
@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// 重新加载时文件出错的处理方式
+const (
+	// ReloadPolicyReplace 出错文件中的prompt从集合中移除
+	ReloadPolicyReplace = "replace"
+	// ReloadPolicyKeepLastGood 出错文件中的prompt保留上一次成功加载的版本
+	ReloadPolicyKeepLastGood = "keep-last-good"
+)
+
+// ReloadDiff 一次加载与上一次加载相比的变化，名称均按字母序排列
+type ReloadDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	// Kept 文件出错而保留旧版本的prompt，仅在keep-last-good策略下出现
+	Kept []string `json:"kept,omitempty"`
+}
+
+// Empty 判断是否没有任何变化
+func (d *ReloadDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Kept) == 0
+}
+
+// String 返回变化的摘要
+func (d *ReloadDiff) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed, %d kept", len(d.Added), len(d.Removed), len(d.Changed), len(d.Kept))
+}
+
+// diffPrompts 比较两个prompt集合，以合并和展开后的定义判断prompt是否变化
+func diffPrompts(previous, current map[string]*Prompt) *ReloadDiff {
+	diff := &ReloadDiff{}
+
+	for name, prompt := range current {
+		old, exists := previous[name]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, name)
+		case old != prompt && !samePrompt(old, prompt):
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range previous {
+		if _, exists := current[name]; !exists {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	diff.Added = sortedNames(diff.Added)
+	diff.Removed = sortedNames(diff.Removed)
+	diff.Changed = sortedNames(diff.Changed)
+
+	return diff
+}
+
+// samePrompt 比较两个prompt的定义是否相同
+func samePrompt(a, b *Prompt) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return string(dataA) == string(dataB)
+}
+
+// sortedNames 对名称排序后返回
+func sortedNames(names []string) []string {
+	sort.Strings(names)
+	return names
+}
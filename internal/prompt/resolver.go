@@ -0,0 +1,71 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dependencyResolver 按依赖顺序处理prompt之间的引用关系（extends、include），
+// 依赖先于引用方处理，每个prompt只处理一次，并检测循环依赖
+type dependencyResolver struct {
+	// kind 依赖的种类，用于循环错误信息，如 extends cycle: a -> b -> a
+	kind    string
+	prompts map[string]*Prompt
+	// apply 处理单个prompt，需要依赖时调用r.resolve先处理依赖
+	apply func(r *dependencyResolver, p *Prompt) error
+
+	resolved map[string]error
+	visiting map[string]bool
+	stack    []string
+}
+
+// resolveDependencies 处理所有prompt，返回处理失败的prompt及其错误
+func resolveDependencies(kind string, prompts map[string]*Prompt, apply func(r *dependencyResolver, p *Prompt) error) map[string]error {
+	r := &dependencyResolver{
+		kind:     kind,
+		prompts:  prompts,
+		apply:    apply,
+		resolved: make(map[string]error),
+		visiting: make(map[string]bool),
+	}
+
+	failed := make(map[string]error)
+	for name := range prompts {
+		if err := r.resolve(name); err != nil {
+			failed[name] = err
+		}
+	}
+
+	return failed
+}
+
+// resolve 处理单个prompt并缓存结果，正在处理中的prompt再次出现时构成循环
+func (r *dependencyResolver) resolve(name string) error {
+	if err, done := r.resolved[name]; done {
+		return err
+	}
+
+	if r.visiting[name] {
+		// 只报告构成循环的部分
+		start := 0
+		for i, visiting := range r.stack {
+			if visiting == name {
+				start = i
+				break
+			}
+		}
+		cycle := append(append([]string{}, r.stack[start:]...), name)
+		return fmt.Errorf("%s cycle: %s", r.kind, strings.Join(cycle, " -> "))
+	}
+
+	r.visiting[name] = true
+	r.stack = append(r.stack, name)
+
+	err := r.apply(r, r.prompts[name])
+
+	r.stack = r.stack[:len(r.stack)-1]
+	delete(r.visiting, name)
+	r.resolved[name] = err
+
+	return err
+}
@@ -0,0 +1,184 @@
+package prompt
+
+import (
+	"math"
+	"sort"
+	"unicode"
+)
+
+// BM25参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 各字段的权重，通过重复词项实现
+const (
+	nameWeight        = 3
+	descriptionWeight = 2
+	tagWeight         = 2
+	argumentWeight    = 1
+)
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Prompt *Prompt
+	Score  float64
+}
+
+// searchDocument 索引中的单个prompt
+type searchDocument struct {
+	prompt *Prompt
+	terms  map[string]int
+	length int
+}
+
+// searchIndex 基于BM25的prompt全文索引
+type searchIndex struct {
+	documents []searchDocument
+	docFreq   map[string]int
+	avgLength float64
+}
+
+// buildSearchIndex 为prompts建立索引，在每次加载后重建
+func buildSearchIndex(prompts map[string]*Prompt) *searchIndex {
+	idx := &searchIndex{
+		documents: make([]searchDocument, 0, len(prompts)),
+		docFreq:   make(map[string]int),
+	}
+
+	totalLength := 0
+	for _, p := range prompts {
+		doc := searchDocument{
+			prompt: p,
+			terms:  make(map[string]int),
+		}
+
+		addTerms := func(text string, weight int) {
+			for _, term := range tokenize(text) {
+				doc.terms[term] += weight
+				doc.length += weight
+			}
+		}
+
+		addTerms(p.Name, nameWeight)
+		addTerms(p.Description, descriptionWeight)
+		addTerms(p.Category, tagWeight)
+		for _, tag := range p.Tags {
+			addTerms(tag, tagWeight)
+		}
+		for _, arg := range p.Arguments {
+			addTerms(arg.Name, argumentWeight)
+			addTerms(arg.Description, argumentWeight)
+		}
+
+		for term := range doc.terms {
+			idx.docFreq[term]++
+		}
+		totalLength += doc.length
+		idx.documents = append(idx.documents, doc)
+	}
+
+	if len(idx.documents) > 0 {
+		idx.avgLength = float64(totalLength) / float64(len(idx.documents))
+	}
+
+	return idx
+}
+
+// search 按BM25得分从高到低返回满足过滤条件的prompt，limit<=0时返回全部
+func (idx *searchIndex) search(query string, limit int, filter Filter) []SearchResult {
+	terms := tokenize(query)
+	if len(terms) == 0 || len(idx.documents) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.documents))
+	results := make([]SearchResult, 0)
+
+	for _, doc := range idx.documents {
+		if !filter.Match(doc.prompt) {
+			continue
+		}
+
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(doc.terms[term])
+			if tf == 0 {
+				continue
+			}
+
+			df := float64(idx.docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(doc.length)/idx.avgLength
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+
+		if score > 0 {
+			results = append(results, SearchResult{Prompt: doc.prompt, Score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Prompt.Name < results[j].Prompt.Name
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// tokenize 将文本切分为小写词项。拉丁字母和数字按词切分，
+// 中日韩文字没有空格分词，连续的字符同时生成单字和相邻双字词项
+func tokenize(text string) []string {
+	var (
+		terms []string
+		word  []rune
+		cjk   []rune
+	)
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+
+	flushCJK := func() {
+		for i := range cjk {
+			terms = append(terms, string(cjk[i]))
+			if i+1 < len(cjk) {
+				terms = append(terms, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return terms
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "latin words lowercased", text: "Code Review", want: []string{"code", "review"}},
+		{name: "underscores and punctuation split", text: "gen_3d_webpage-html!", want: []string{"gen", "3d", "webpage", "html"}},
+		{name: "cjk unigrams and bigrams", text: "网页生成", want: []string{"网", "网页", "页", "页生", "生", "生成", "成"}},
+		{name: "single cjk character", text: "码", want: []string{"码"}},
+		{name: "mixed scripts", text: "3D网页", want: []string{"3d", "网", "网页", "页"}},
+		{name: "kana", text: "テスト", want: []string{"テ", "テス", "ス", "スト", "ト"}},
+		{name: "empty", text: " ,. ", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	prompts := map[string]*Prompt{
+		"code_review": {
+			Name:        "code_review",
+			Description: "审查代码质量",
+			Metadata:    Metadata{Tags: []string{"dev"}, Category: "development"},
+		},
+		"api_documentation": {
+			Name:        "api_documentation",
+			Description: "为代码生成API文档",
+			Metadata:    Metadata{Tags: []string{"dev", "docs"}, Category: "development"},
+		},
+		"gen_3d_webpage_html": {
+			Name:        "gen_3d_webpage_html",
+			Description: "生成3D网页",
+			Arguments:   []Argument{{Name: "topic", Description: "网页主题"}},
+			Metadata:    Metadata{Category: "design"},
+		},
+	}
+	idx := buildSearchIndex(prompts)
+
+	tests := []struct {
+		name   string
+		query  string
+		limit  int
+		filter Filter
+		want   []string
+	}{
+		{name: "name match", query: "review", want: []string{"code_review"}},
+		{name: "cjk query", query: "网页", want: []string{"gen_3d_webpage_html"}},
+		{name: "name outweighs description", query: "代码 api", want: []string{"api_documentation", "code_review"}},
+		{name: "shorter document ranks first with limit", query: "代码", limit: 1, want: []string{"code_review"}},
+		{name: "tag filter", query: "代码", filter: Filter{Tag: "DOCS"}, want: []string{"api_documentation"}},
+		{name: "category filter", query: "生成", filter: Filter{Category: "design"}, want: []string{"gen_3d_webpage_html"}},
+		{name: "argument description indexed", query: "主题", want: []string{"gen_3d_webpage_html"}},
+		{name: "no match", query: "kubernetes", want: nil},
+		{name: "empty query", query: "  ", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, result := range idx.search(tt.query, tt.limit, tt.filter) {
+				got = append(got, result.Prompt.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndexScoresDescending(t *testing.T) {
+	idx := buildSearchIndex(map[string]*Prompt{
+		"a": {Name: "a", Description: "review"},
+		"b": {Name: "b", Description: "review review code"},
+		"c": {Name: "c", Description: "code"},
+	})
+
+	results := idx.search("review code", 0, Filter{})
+	if len(results) != 3 {
+		t.Fatalf("search() returned %d results, want 3", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("results not sorted by score: %v > %v", results[i].Score, results[i-1].Score)
+		}
+	}
+	if results[0].Prompt.Name != "b" {
+		t.Errorf("top result = %s, want b", results[0].Prompt.Name)
+	}
+}
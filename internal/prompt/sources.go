@@ -0,0 +1,102 @@
+package prompt
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// 默认的来源名称，按优先级从低到高排列
+const (
+	SourceBuiltin = "builtin"
+	SourceUser    = "user"
+	SourceProject = "project"
+)
+
+// PromptSource prompt文件的来源。Manager按顺序叠加多个来源，后面的来源按名称覆盖前面的同名prompt，
+// 命名空间由文件在来源中的相对路径决定。除文件系统外，也可以接入数据库、远程仓库等后端
+type PromptSource interface {
+	// Name 来源名称，如builtin、user、project
+	Name() string
+	// Location 来源位置，如目录路径；与文件相对路径拼接后用于诊断和来源信息
+	Location() string
+	// List 返回所有prompt文件以/分隔的相对路径，来源不存在时返回空列表
+	List() ([]string, error)
+	// Read 读取List返回的文件内容
+	Read(path string) ([]byte, error)
+	// Watch 在来源内容变化时调用changed，返回停止监控的函数；不支持监控的来源返回nil, nil
+	Watch(changed func()) (stop func() error, err error)
+}
+
+// Origin prompt的来源信息
+type Origin struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	// Overridden 被该prompt覆盖的较早来源，按加载顺序排列
+	Overridden []string `json:"overridden,omitempty"`
+
+	// 来源在Manager中的位置，越大优先级越高
+	layer int
+}
+
+// FSSource 基于只读文件系统的来源，如go:embed嵌入的目录，不支持监控
+type FSSource struct {
+	name     string
+	location string
+	fsys     fs.FS
+}
+
+// NewFSSource 创建基于fs.FS的来源，location用于显示，如 embed:prompts
+func NewFSSource(name, location string, fsys fs.FS) *FSSource {
+	return &FSSource{name: name, location: location, fsys: fsys}
+}
+
+// Name 来源名称
+func (s *FSSource) Name() string {
+	return s.name
+}
+
+// Location 来源位置
+func (s *FSSource) Location() string {
+	return s.location
+}
+
+// List 列出文件系统中所有prompt文件
+func (s *FSSource) List() ([]string, error) {
+	return listPromptFiles(s.fsys)
+}
+
+// Read 读取文件内容
+func (s *FSSource) Read(name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, name)
+}
+
+// Watch 只读文件系统不会变化，不需要监控
+func (s *FSSource) Watch(changed func()) (func() error, error) {
+	return nil, nil
+}
+
+// listPromptFiles 递归列出文件系统中的yaml和json文件，按路径排序
+func listPromptFiles(fsys fs.FS) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isPromptFile(name) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// sourcePath 返回来源中文件的显示路径
+func sourcePath(source PromptSource, rel string) string {
+	return path.Join(source.Location(), rel)
+}
@@ -0,0 +1,168 @@
+package prompt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// 支持的模板引擎
+const (
+	// EngineSimple 默认引擎，只替换 {{name}} 占位符
+	EngineSimple = "simple"
+	// EngineGoTemplate 基于text/template，支持条件、循环和过滤器
+	EngineGoTemplate = "gotemplate"
+)
+
+// templateFuncs gotemplate引擎可用的过滤器
+var templateFuncs = template.FuncMap{
+	"indent":  indentFilter,
+	"upper":   func(value interface{}) string { return strings.ToUpper(formatValue(value)) },
+	"lower":   func(value interface{}) string { return strings.ToLower(formatValue(value)) },
+	"trim":    func(value interface{}) string { return strings.TrimSpace(formatValue(value)) },
+	"json":    jsonFilter,
+	"default": defaultFilter,
+	"join":    joinFilter,
+}
+
+// Compile 按prompt声明的引擎预编译消息模板，加载时调用以便尽早发现模板错误
+func (p *Prompt) Compile() error {
+	switch p.Engine {
+	case "", EngineSimple:
+		p.templates = nil
+		return nil
+	case EngineGoTemplate:
+	default:
+		return fmt.Errorf("unsupported template engine: %q", p.Engine)
+	}
+
+	templates := make([]*template.Template, len(p.Messages))
+	for i, message := range p.Messages {
+		if message.Content.Type != "text" {
+			continue
+		}
+
+		tmpl, err := template.New(fmt.Sprintf("%s#%d", p.Name, i)).
+			Funcs(templateFuncs).
+			Parse(message.Content.Text)
+		if err != nil {
+			return atPosition(message.textPos, fmt.Errorf("failed to compile message %d: %w", i, err))
+		}
+
+		// 严格模式下引用不存在的参数视为错误
+		if p.Strict {
+			tmpl.Option("missingkey=error")
+		}
+		templates[i] = tmpl
+	}
+
+	p.templates = templates
+	return nil
+}
+
+// renderTemplate 使用预编译的模板渲染第i条消息
+func (p *Prompt) renderTemplate(i int, args map[string]interface{}) (string, error) {
+	if i >= len(p.templates) || p.templates[i] == nil {
+		return "", fmt.Errorf("message %d of prompt %s is not compiled", i, p.Name)
+	}
+
+	// 已声明但未提供的参数：字符串类型按空字符串处理，直接输出时不会出现 <no value>；
+	// 其他类型设为nil，range、if和default都能正确处理
+	data := make(map[string]interface{}, len(args)+len(p.Arguments))
+	for _, arg := range p.Arguments {
+		switch arg.EffectiveType() {
+		case TypeString, TypeEnum:
+			data[arg.Name] = ""
+		default:
+			data[arg.Name] = nil
+		}
+	}
+	for name, value := range args {
+		data[name] = value
+	}
+
+	var buf strings.Builder
+	if err := p.templates[i].Execute(&buf, data); err != nil {
+		if argErr, ok := templateArgumentError(err); ok {
+			return "", ArgumentErrors{argErr}
+		}
+		return "", fmt.Errorf("failed to render message %d: %w", i, err)
+	}
+
+	return buf.String(), nil
+}
+
+// execErrorPattern 匹配text/template执行错误中出错的参数及原因，如
+// template: x#0:1:8: executing "x#0" at <.items>: range can't iterate over 5
+var execErrorPattern = regexp.MustCompile(`at <\.([\p{L}\p{N}_]+)[^>]*>: (.*)$`)
+
+// templateArgumentError 将由参数值引起的模板执行错误转换为参数错误
+func templateArgumentError(err error) (ArgumentError, bool) {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return ArgumentError{}, false
+	}
+
+	groups := execErrorPattern.FindStringSubmatch(execErr.Error())
+	if groups == nil || groups[1] == BuiltinNamespace {
+		return ArgumentError{}, false
+	}
+
+	return ArgumentError{Name: groups[1], Message: groups[2]}, true
+}
+
+// indentFilter 为每一行添加指定数量的空格缩进
+func indentFilter(spaces int, value interface{}) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(formatValue(value), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// jsonFilter 将值序列化为JSON
+func jsonFilter(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// defaultFilter 值为空时返回默认值
+func defaultFilter(def, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return def
+		}
+	}
+
+	return value
+}
+
+// joinFilter 用分隔符连接数组元素
+func joinFilter(sep string, value interface{}) string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return formatValue(value)
+	}
+
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = formatValue(item)
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,71 @@
+package prompt
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTemplatePrompt(t *testing.T, text string, args ...Argument) *Prompt {
+	t.Helper()
+
+	p := &Prompt{
+		Name:      "t",
+		Engine:    EngineGoTemplate,
+		Arguments: args,
+		Messages:  []Message{{Role: RoleUser, Content: Content{Type: "text", Text: text}}},
+	}
+	if err := p.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	return p
+}
+
+func TestRenderTemplateOmittedArguments(t *testing.T) {
+	p := newTemplatePrompt(t,
+		`{{range .items}}- {{.}}{{end}}{{if .count}}n{{end}}{{.topic}}|{{default "x" .items}}`,
+		Argument{Name: "items", Type: TypeArray},
+		Argument{Name: "count", Type: TypeInteger},
+		Argument{Name: "topic"},
+	)
+
+	messages, err := p.Execute(map[string]interface{}{}, nil)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got, want := messages[0].Content.Text, "|x"; got != want {
+		t.Errorf("Execute() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTemplateArgumentError(t *testing.T) {
+	p := newTemplatePrompt(t, `{{range .items}}{{.}}{{end}}`, Argument{Name: "items", Type: TypeBoolean})
+
+	_, err := p.Execute(map[string]interface{}{"items": true}, nil)
+
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) {
+		t.Fatalf("Execute() error = %v, want ArgumentErrors", err)
+	}
+	if len(argErrs) != 1 || argErrs[0].Name != "items" {
+		t.Errorf("Execute() errors = %v, want one error for items", argErrs)
+	}
+}
+
+func TestRenderTemplateStrictMissingKey(t *testing.T) {
+	p := &Prompt{
+		Name:     "t",
+		Engine:   EngineGoTemplate,
+		Strict:   true,
+		Messages: []Message{{Role: RoleUser, Content: Content{Type: "text", Text: `{{.undeclared}}`}}},
+	}
+	if err := p.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	_, err := p.Execute(map[string]interface{}{}, nil)
+
+	var argErrs ArgumentErrors
+	if !errors.As(err, &argErrs) || argErrs[0].Name != "undeclared" {
+		t.Errorf("Execute() error = %v, want ArgumentErrors for undeclared", err)
+	}
+}
@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"fmt"
+	"log"
+)
+
+// StartWatching 监控所有支持监控的来源，内容变化时重新加载。已在监控时直接返回
+func (m *Manager) StartWatching() error {
+	m.watchMutex.Lock()
+	defer m.watchMutex.Unlock()
+
+	if m.watching {
+		return nil
+	}
+
+	var stops []func() error
+	for _, source := range m.sources {
+		stop, err := source.Watch(m.sourceChanged)
+		if err != nil {
+			for _, stop := range stops {
+				stop()
+			}
+			return fmt.Errorf("failed to watch %s prompts source: %w", source.Name(), err)
+		}
+		if stop != nil {
+			stops = append(stops, stop)
+		}
+	}
+
+	m.watching = true
+	m.watchStops = stops
+
+	log.Printf("Started file watching for %d prompt sources", len(stops))
+	return nil
+}
+
+// StopWatching 停止所有来源的监控，未在监控时直接返回
+func (m *Manager) StopWatching() error {
+	m.watchMutex.Lock()
+	defer m.watchMutex.Unlock()
+
+	if !m.watching {
+		return nil
+	}
+
+	var firstErr error
+	for _, stop := range m.watchStops {
+		if err := stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	m.watching = false
+	m.watchStops = nil
+
+	log.Printf("Stopped file watching")
+	return firstErr
+}
+
+// IsWatching 返回是否正在监控来源
+func (m *Manager) IsWatching() bool {
+	m.watchMutex.Lock()
+	defer m.watchMutex.Unlock()
+
+	return m.watching
+}
+
+// sourceChanged 来源内容变化后重新加载
+func (m *Manager) sourceChanged() {
+	if _, err := m.LoadPrompts(); err != nil {
+		log.Printf("Failed to reload prompts: %v", err)
+	}
+}
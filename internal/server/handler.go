@@ -0,0 +1,528 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"mcp-prompt-server/internal/mcp"
+	"mcp-prompt-server/internal/prompt"
+)
+
+// 支持的MCP协议版本，第一个为最新版本
+var supportedProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+// Transport 传输层服务器，Start阻塞直到服务结束
+type Transport interface {
+	Start() error
+}
+
+// Session 一个客户端连接的会话状态
+type Session struct {
+	ID string
+
+	// 客户端完成初始化后才发送通知
+	initialized atomic.Bool
+	notify      func(notification *mcp.MCPNotification) error
+
+	// 客户端在initialize请求中提供的信息
+	clientMutex sync.RWMutex
+	clientInfo  mcp.ClientInfo
+
+	// 正在处理的请求，按请求ID记录取消函数
+	inflightMutex sync.Mutex
+	inflight      map[string]context.CancelFunc
+}
+
+// Handler 与传输层无关的MCP请求分发器，供各个传输实现共享
+type Handler struct {
+	mcpServer     *mcp.Server
+	promptManager *prompt.Manager
+
+	sessionsMutex sync.RWMutex
+	sessions      map[*Session]struct{}
+
+	// 上次通知时的工具和prompt列表快照，用于判断列表是否变化
+	snapshotMutex   sync.Mutex
+	toolsSnapshot   string
+	promptsSnapshot string
+}
+
+// NewHandler 创建请求分发器，并在prompts重新加载后向会话推送列表变更通知
+func NewHandler(mcpServer *mcp.Server, promptManager *prompt.Manager) *Handler {
+	h := &Handler{
+		mcpServer:     mcpServer,
+		promptManager: promptManager,
+		sessions:      make(map[*Session]struct{}),
+	}
+
+	h.toolsSnapshot, h.promptsSnapshot = h.snapshotLists()
+	promptManager.OnReload(h.notifyListChanged)
+
+	return h
+}
+
+// NewSession 创建会话，notify用于向该会话的客户端推送通知
+func (h *Handler) NewSession(id string, notify func(notification *mcp.MCPNotification) error) *Session {
+	session := &Session{
+		ID:       id,
+		notify:   notify,
+		inflight: make(map[string]context.CancelFunc),
+	}
+
+	h.sessionsMutex.Lock()
+	h.sessions[session] = struct{}{}
+	h.sessionsMutex.Unlock()
+
+	return session
+}
+
+// CloseSession 关闭会话，取消其所有正在处理的请求，之后不再向其推送通知
+func (h *Handler) CloseSession(session *Session) {
+	h.sessionsMutex.Lock()
+	delete(h.sessions, session)
+	h.sessionsMutex.Unlock()
+
+	session.inflightMutex.Lock()
+	for _, cancel := range session.inflight {
+		cancel()
+	}
+	session.inflightMutex.Unlock()
+}
+
+// HandleMessage 处理一条JSON-RPC消息，通知、客户端响应和已取消的请求返回nil。
+// 可以并发调用，ctx取消或收到notifications/cancelled时中止对应请求
+func (h *Handler) HandleMessage(ctx context.Context, session *Session, data []byte) *mcp.MCPResponse {
+	var request mcp.MCPRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return newErrorResponse(nil, -32700, "Parse error", err.Error())
+	}
+
+	// 没有ID的消息是通知，不需要响应
+	if request.ID == nil {
+		h.handleNotification(session, &request)
+		return nil
+	}
+
+	// 客户端对服务器请求的响应，目前无需处理
+	if request.Method == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	key := requestKey(request.ID)
+	session.track(key, cancel)
+	defer session.untrack(key)
+
+	var (
+		result interface{}
+		rpcErr *mcp.MCPError
+	)
+
+	switch request.Method {
+	case "initialize":
+		result, rpcErr = h.handleInitialize(session, &request)
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		result, rpcErr = h.handleListTools(&request)
+	case "tools/call":
+		result, rpcErr = h.handleCallTool(mcp.WithClientInfo(ctx, session.ClientInfo()), &request)
+	case "prompts/list":
+		result, rpcErr = h.handleListPrompts(&request)
+	case "prompts/get":
+		result, rpcErr = h.handleGetPrompt(session, &request)
+	default:
+		rpcErr = newError(-32601, "Method not found", fmt.Sprintf("Unknown method: %s", request.Method))
+	}
+
+	// 已取消的请求不再发送响应
+	if ctx.Err() != nil {
+		log.Printf("Request %v (%s) cancelled", request.ID, request.Method)
+		return nil
+	}
+
+	if rpcErr != nil {
+		return &mcp.MCPResponse{JSONRPC: "2.0", ID: request.ID, Error: rpcErr}
+	}
+
+	return &mcp.MCPResponse{JSONRPC: "2.0", ID: request.ID, Result: result}
+}
+
+// HandlePayload 处理一条消息或JSON-RPC批量消息，返回需要发送给客户端的内容：
+// 单条消息的响应或批量消息的响应数组，没有需要发送的响应时返回nil。供逐条传输消息的stdio和SSE使用
+func (h *Handler) HandlePayload(ctx context.Context, session *Session, data []byte) interface{} {
+	messages, batch, err := splitMessages(data)
+	if err != nil {
+		return newErrorResponse(nil, -32700, "Parse error", err.Error())
+	}
+
+	return batchReply(h.HandleMessages(ctx, session, messages), batch)
+}
+
+// HandleMessages 并发处理多条消息，按原有顺序返回需要发送的响应，通知等没有响应的消息不包含在内
+func (h *Handler) HandleMessages(ctx context.Context, session *Session, messages []json.RawMessage) []*mcp.MCPResponse {
+	if len(messages) == 1 {
+		if response := h.HandleMessage(ctx, session, messages[0]); response != nil {
+			return []*mcp.MCPResponse{response}
+		}
+		return nil
+	}
+
+	results := make([]*mcp.MCPResponse, len(messages))
+	var wg sync.WaitGroup
+	for i, message := range messages {
+		wg.Add(1)
+		go func(i int, message json.RawMessage) {
+			defer wg.Done()
+			results[i] = h.HandleMessage(ctx, session, message)
+		}(i, message)
+	}
+	wg.Wait()
+
+	responses := make([]*mcp.MCPResponse, 0, len(results))
+	for _, response := range results {
+		if response != nil {
+			responses = append(responses, response)
+		}
+	}
+	return responses
+}
+
+// splitMessages 将请求体拆分为单条消息，batch表示请求体是否为JSON数组
+func splitMessages(body []byte) (messages []json.RawMessage, batch bool, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &messages); err != nil {
+			return nil, true, err
+		}
+		if len(messages) == 0 {
+			return nil, true, errors.New("empty batch")
+		}
+		return messages, true, nil
+	}
+
+	if !json.Valid(body) {
+		return nil, false, errors.New("invalid JSON")
+	}
+
+	return []json.RawMessage{body}, false, nil
+}
+
+// containsInitialize 判断消息中是否包含initialize请求
+func containsInitialize(messages []json.RawMessage) bool {
+	for _, message := range messages {
+		var request struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(message, &request) == nil && request.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
+
+// batchReply 按请求形式组织响应：批量请求返回数组，单条请求返回单个响应，没有响应时返回nil
+func batchReply(responses []*mcp.MCPResponse, batch bool) interface{} {
+	if len(responses) == 0 {
+		return nil
+	}
+	if batch {
+		return responses
+	}
+	return responses[0]
+}
+
+// handleNotification 处理客户端通知
+func (h *Handler) handleNotification(session *Session, request *mcp.MCPRequest) {
+	switch request.Method {
+	case "notifications/initialized":
+		session.initialized.Store(true)
+	case "notifications/cancelled":
+		var params mcp.CancelledParams
+		if err := decodeParams(request, &params); err != nil || params.RequestID == nil {
+			return
+		}
+		session.cancel(requestKey(params.RequestID))
+	default:
+		// 忽略其他通知
+	}
+}
+
+// handleInitialize 处理初始化请求
+func (h *Handler) handleInitialize(session *Session, request *mcp.MCPRequest) (interface{}, *mcp.MCPError) {
+	var params struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		ClientInfo      mcp.ClientInfo `json:"clientInfo"`
+	}
+	if err := decodeParams(request, &params); err != nil {
+		return nil, newError(-32602, "Invalid params", err.Error())
+	}
+
+	session.clientMutex.Lock()
+	session.clientInfo = params.ClientInfo
+	session.clientMutex.Unlock()
+
+	// 客户端请求的版本受支持时沿用，否则返回最新版本
+	protocolVersion := supportedProtocolVersions[0]
+	for _, version := range supportedProtocolVersions {
+		if version == params.ProtocolVersion {
+			protocolVersion = version
+			break
+		}
+	}
+
+	result := map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities": map[string]interface{}{
+			"tools": map[string]interface{}{
+				"listChanged": true,
+			},
+			"prompts": map[string]interface{}{
+				"listChanged": true,
+			},
+		},
+		"serverInfo": h.mcpServer.GetServerInfo(),
+	}
+
+	return result, nil
+}
+
+// handleListTools 处理工具列表请求
+func (h *Handler) handleListTools(request *mcp.MCPRequest) (interface{}, *mcp.MCPError) {
+	return mcp.ListToolsResult{Tools: h.mcpServer.ListTools()}, nil
+}
+
+// handleCallTool 处理工具调用请求
+func (h *Handler) handleCallTool(ctx context.Context, request *mcp.MCPRequest) (interface{}, *mcp.MCPError) {
+	// 解析参数
+	var params mcp.ToolCallParams
+	if err := decodeParams(request, &params); err != nil {
+		return nil, newError(-32602, "Invalid params", err.Error())
+	}
+
+	// 调用工具
+	result, err := h.mcpServer.CallTool(ctx, params.Name, params.Arguments)
+	if err != nil {
+		return nil, newError(-32603, "Internal error", err.Error())
+	}
+
+	return result, nil
+}
+
+// handleListPrompts 处理prompt列表请求
+func (h *Handler) handleListPrompts(request *mcp.MCPRequest) (interface{}, *mcp.MCPError) {
+	return mcp.ListPromptsResult{Prompts: h.listPromptInfos()}, nil
+}
+
+// handleGetPrompt 处理获取prompt请求
+func (h *Handler) handleGetPrompt(session *Session, request *mcp.MCPRequest) (interface{}, *mcp.MCPError) {
+	var params mcp.GetPromptParams
+	if err := decodeParams(request, &params); err != nil {
+		return nil, newError(-32602, "Invalid params", err.Error())
+	}
+
+	p, exists := h.promptManager.GetPrompt(params.Name)
+	if !exists {
+		return nil, newError(-32602, "Invalid params", fmt.Sprintf("Prompt not found: %s", params.Name))
+	}
+
+	// prompts/get 的参数均为字符串
+	args := make(map[string]interface{}, len(params.Arguments))
+	for name, value := range params.Arguments {
+		args[name] = value
+	}
+
+	client := session.ClientInfo()
+	messages, err := p.Execute(args, h.promptManager.BuiltinVariables(client.Name, client.Version))
+	if err != nil {
+		var argErrs prompt.ArgumentErrors
+		if errors.As(err, &argErrs) {
+			return nil, &mcp.MCPError{Code: -32602, Message: "Invalid params", Data: argErrs}
+		}
+		return nil, newError(-32603, "Internal error", err.Error())
+	}
+
+	result := mcp.GetPromptResult{
+		Description: p.Description,
+		Messages:    make([]mcp.PromptMessage, 0, len(messages)),
+	}
+	for _, message := range messages {
+		result.Messages = append(result.Messages, buildPromptMessage(message))
+	}
+
+	return result, nil
+}
+
+// buildPromptMessage 将渲染后的消息转换为MCP prompt消息。
+// MCP只支持user和assistant角色，system消息以user角色发送，原始角色记录在注解中
+func buildPromptMessage(message prompt.Message) mcp.PromptMessage {
+	role := message.Role
+	if role == prompt.RoleSystem {
+		role = prompt.RoleUser
+	}
+
+	return mcp.PromptMessage{
+		Role: role,
+		Content: mcp.Content{
+			Type:        "text",
+			Text:        message.Content.Text,
+			Annotations: &mcp.Annotations{Role: message.Role},
+		},
+	}
+}
+
+// listPromptInfos 返回按名称排序的prompt信息列表
+func (h *Handler) listPromptInfos() []mcp.PromptInfo {
+	prompts := h.promptManager.GetPrompts()
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+
+	infos := make([]mcp.PromptInfo, 0, len(prompts))
+	for _, p := range prompts {
+		infos = append(infos, buildPromptInfo(p))
+	}
+
+	return infos
+}
+
+// snapshotLists 序列化当前的工具和prompt列表
+func (h *Handler) snapshotLists() (string, string) {
+	toolsBytes, _ := json.Marshal(h.mcpServer.ListTools())
+	promptsBytes, _ := json.Marshal(h.listPromptInfos())
+	return string(toolsBytes), string(promptsBytes)
+}
+
+// notifyListChanged 在prompts重新加载后，若列表发生变化则通知所有已初始化的会话
+func (h *Handler) notifyListChanged() {
+	h.snapshotMutex.Lock()
+	tools, prompts := h.snapshotLists()
+	toolsChanged := tools != h.toolsSnapshot
+	promptsChanged := prompts != h.promptsSnapshot
+	h.toolsSnapshot, h.promptsSnapshot = tools, prompts
+	h.snapshotMutex.Unlock()
+
+	if toolsChanged {
+		h.broadcast("notifications/tools/list_changed")
+	}
+
+	if promptsChanged {
+		h.broadcast("notifications/prompts/list_changed")
+	}
+}
+
+// broadcast 向所有已初始化的会话发送通知
+func (h *Handler) broadcast(method string) {
+	notification := &mcp.MCPNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+	}
+
+	h.sessionsMutex.RLock()
+	defer h.sessionsMutex.RUnlock()
+
+	for session := range h.sessions {
+		if !session.initialized.Load() || session.notify == nil {
+			continue
+		}
+
+		if err := session.notify(notification); err != nil {
+			log.Printf("Failed to send %s notification: %v", method, err)
+		}
+	}
+}
+
+// ClientInfo 返回客户端在initialize请求中提供的信息
+func (session *Session) ClientInfo() mcp.ClientInfo {
+	session.clientMutex.RLock()
+	defer session.clientMutex.RUnlock()
+
+	return session.clientInfo
+}
+
+// track 记录正在处理的请求
+func (session *Session) track(key string, cancel context.CancelFunc) {
+	session.inflightMutex.Lock()
+	session.inflight[key] = cancel
+	session.inflightMutex.Unlock()
+}
+
+// untrack 移除已完成的请求
+func (session *Session) untrack(key string) {
+	session.inflightMutex.Lock()
+	delete(session.inflight, key)
+	session.inflightMutex.Unlock()
+}
+
+// cancel 取消正在处理的请求，请求不存在时忽略
+func (session *Session) cancel(key string) {
+	session.inflightMutex.Lock()
+	cancel, exists := session.inflight[key]
+	session.inflightMutex.Unlock()
+
+	if exists {
+		cancel()
+	}
+}
+
+// requestKey 将请求ID转换为map键，区分数字和字符串ID
+func requestKey(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+// buildPromptInfo 将prompt转换为MCP prompt信息
+func buildPromptInfo(p *prompt.Prompt) mcp.PromptInfo {
+	info := mcp.PromptInfo{
+		Name:        p.Name,
+		Description: p.Description,
+		Meta:        p.Meta(),
+	}
+
+	for i := range p.Arguments {
+		arg := &p.Arguments[i]
+		info.Arguments = append(info.Arguments, mcp.PromptArgumentInfo{
+			Name:        arg.Name,
+			Description: arg.Description,
+			Required:    arg.IsRequired(),
+		})
+	}
+
+	return info
+}
+
+// decodeParams 将请求参数解码到目标结构
+func decodeParams(request *mcp.MCPRequest, target interface{}) error {
+	paramsBytes, err := json.Marshal(request.Params)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(paramsBytes, target)
+}
+
+// newError 创建JSON-RPC错误
+func newError(code int, message, data string) *mcp.MCPError {
+	return &mcp.MCPError{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	}
+}
+
+// newErrorResponse 创建JSON-RPC错误响应
+func newErrorResponse(id interface{}, code int, message, data string) *mcp.MCPResponse {
+	return &mcp.MCPResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   newError(code, message, data),
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"mcp-prompt-server/internal/mcp"
+	"mcp-prompt-server/internal/prompt"
+)
+
+// testPrompt 测试用的prompt文件
+const testPrompt = `name: greet
+arguments:
+  - name: who
+    required: true
+messages:
+  - role: user
+    content:
+      type: text
+      text: hello {{who}}
+`
+
+// testEnv 测试用的Handler及其依赖
+type testEnv struct {
+	handler *Handler
+	server  *mcp.Server
+	manager *prompt.Manager
+	source  *prompt.MemorySource
+
+	// slow工具开始执行和结束时的信号，结束时发送ctx是否已取消
+	slowStarted chan struct{}
+	slowDone    chan bool
+}
+
+// newTestEnv 创建带有slow工具和一个prompt的Handler
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		server:      mcp.NewServer("test", "0.0.0"),
+		source:      prompt.NewMemorySource(prompt.SourceProject),
+		slowStarted: make(chan struct{}, 8),
+		slowDone:    make(chan bool, 8),
+	}
+
+	env.source.Set("greet.yaml", []byte(testPrompt))
+	env.manager = prompt.NewManager(env.source)
+	if _, err := env.manager.LoadPrompts(); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+
+	env.server.RegisterTool(&mcp.Tool{
+		Name:      "slow",
+		Arguments: map[string]interface{}{},
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			env.slowStarted <- struct{}{}
+			select {
+			case <-ctx.Done():
+				env.slowDone <- true
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				env.slowDone <- false
+				return &mcp.ToolResult{Content: []mcp.Content{{Type: "text", Text: "done"}}}, nil
+			}
+		},
+	})
+
+	env.handler = NewHandler(env.server, env.manager)
+	return env
+}
+
+// waitSignal 等待通道中的值，超时则测试失败
+func waitSignal[T any](t *testing.T, ch <-chan T, what string) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+		var zero T
+		return zero
+	}
+}
+
+func TestHandleMessageCancelled(t *testing.T) {
+	tests := []struct {
+		name       string
+		requestID  string
+		cancelID   string
+		wantCancel bool
+	}{
+		{name: "numeric id", requestID: `7`, cancelID: `7`, wantCancel: true},
+		{name: "string id", requestID: `"a-1"`, cancelID: `"a-1"`, wantCancel: true},
+		{name: "string does not cancel number", requestID: `7`, cancelID: `"7"`, wantCancel: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			session := env.handler.NewSession("test", nil)
+			defer env.handler.CloseSession(session)
+
+			responses := make(chan *mcp.MCPResponse, 1)
+			go func() {
+				responses <- env.handler.HandleMessage(context.Background(), session,
+					[]byte(`{"jsonrpc":"2.0","id":`+tt.requestID+`,"method":"tools/call","params":{"name":"slow"}}`))
+			}()
+			waitSignal(t, env.slowStarted, "slow tool to start")
+
+			cancel := `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":` + tt.cancelID + `}}`
+			if response := env.handler.HandleMessage(context.Background(), session, []byte(cancel)); response != nil {
+				t.Fatalf("notification got response %+v", response)
+			}
+
+			if !tt.wantCancel {
+				select {
+				case <-env.slowDone:
+					t.Fatalf("request cancelled by a different id")
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+
+			if cancelled := waitSignal(t, env.slowDone, "slow tool to stop"); !cancelled {
+				t.Errorf("tool context was not cancelled")
+			}
+			if response := waitSignal(t, responses, "HandleMessage to return"); response != nil {
+				t.Errorf("cancelled request got response %+v", response)
+			}
+		})
+	}
+}
+
+func TestCloseSessionCancelsRequests(t *testing.T) {
+	env := newTestEnv(t)
+	session := env.handler.NewSession("test", nil)
+
+	responses := make(chan *mcp.MCPResponse, 1)
+	go func() {
+		responses <- env.handler.HandleMessage(context.Background(), session,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`))
+	}()
+	waitSignal(t, env.slowStarted, "slow tool to start")
+
+	env.handler.CloseSession(session)
+
+	if response := waitSignal(t, responses, "HandleMessage to return"); response != nil {
+		t.Errorf("request of closed session got response %+v", response)
+	}
+}
+
+func TestHandlePayload(t *testing.T) {
+	env := newTestEnv(t)
+	session := env.handler.NewSession("test", nil)
+	defer env.handler.CloseSession(session)
+
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{
+			name:    "single request",
+			payload: `{"jsonrpc":"2.0","id":1,"method":"ping"}`,
+			want:    `{"jsonrpc":"2.0","id":1,"result":{}}`,
+		},
+		{
+			name:    "notification",
+			payload: `{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			want:    `null`,
+		},
+		{
+			name:    "batch keeps order and skips notifications",
+			payload: `[{"jsonrpc":"2.0","id":"a","method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"nope"}]`,
+			want:    `[{"jsonrpc":"2.0","id":"a","result":{}},{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"Method not found","data":"Unknown method: nope"}}]`,
+		},
+		{
+			name:    "batch of notifications",
+			payload: `[{"jsonrpc":"2.0","method":"notifications/initialized"}]`,
+			want:    `null`,
+		},
+		{
+			name:    "empty batch",
+			payload: `[]`,
+			want:    `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error","data":"empty batch"}}`,
+		},
+		{
+			name:    "invalid json",
+			payload: `{"jsonrpc":`,
+			want:    `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error","data":"invalid JSON"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := env.handler.HandlePayload(context.Background(), session, []byte(tt.payload))
+			got, err := json.Marshal(reply)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("HandlePayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadNotifiesInitializedSessions(t *testing.T) {
+	env := newTestEnv(t)
+
+	notifications := make(chan string, 8)
+	notify := func(notification *mcp.MCPNotification) error {
+		notifications <- notification.Method
+		return nil
+	}
+
+	initialized := env.handler.NewSession("initialized", notify)
+	defer env.handler.CloseSession(initialized)
+	env.handler.HandleMessage(context.Background(), initialized, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+
+	pending := env.handler.NewSession("pending", func(notification *mcp.MCPNotification) error {
+		t.Errorf("uninitialized session notified: %s", notification.Method)
+		return nil
+	})
+	defer env.handler.CloseSession(pending)
+
+	// 内容没有变化的重新加载不发送通知
+	if _, err := env.manager.LoadPrompts(); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+	select {
+	case method := <-notifications:
+		t.Fatalf("unexpected notification %s", method)
+	default:
+	}
+
+	env.source.Set("other.yaml", []byte(`name: other
+messages:
+  - role: user
+    content:
+      type: text
+      text: other
+`))
+	if _, err := env.manager.LoadPrompts(); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+
+	if method := waitSignal(t, notifications, "list_changed"); method != "notifications/prompts/list_changed" {
+		t.Errorf("notification = %s, want notifications/prompts/list_changed", method)
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		same bool
+	}{
+		{a: float64(1), b: float64(1), same: true},
+		{a: "1", b: "1", same: true},
+		{a: float64(1), b: "1", same: false},
+		{a: float64(1), b: float64(2), same: false},
+	}
+
+	for _, tt := range tests {
+		if got := requestKey(tt.a) == requestKey(tt.b); got != tt.same {
+			t.Errorf("requestKey(%#v) == requestKey(%#v) is %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
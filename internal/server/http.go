@@ -0,0 +1,495 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"mcp-prompt-server/internal/mcp"
+	"mcp-prompt-server/internal/prompt"
+)
+
+const (
+	sessionIDHeader = "Mcp-Session-Id"
+
+	// 单个请求体的最大字节数
+	maxRequestBodySize = 4 << 20
+
+	// 每个SSE流缓冲的待发送消息数，缓冲满时丢弃通知
+	streamBufferSize = 16
+
+	// DefaultSessionIdleTimeout 没有打开的SSE流且没有请求的会话在此时长后过期
+	DefaultSessionIdleTimeout = 30 * time.Minute
+)
+
+// HTTPServer Streamable HTTP传输服务器，在单个端点上处理POST、GET和DELETE
+type HTTPServer struct {
+	handler       *Handler
+	promptManager *prompt.Manager
+	addr          string
+	endpoint      string
+
+	sessionsMutex sync.RWMutex
+	sessions      map[string]*httpSession
+	idleTimeout   time.Duration
+}
+
+// httpSession 一个Streamable HTTP会话及其打开的SSE流
+type httpSession struct {
+	session *Session
+
+	mutex      sync.Mutex
+	streams    map[chan []byte]struct{}
+	lastActive time.Time
+}
+
+// NewHTTPServer 创建新的Streamable HTTP服务器
+func NewHTTPServer(mcpServer *mcp.Server, promptManager *prompt.Manager, addr, endpoint string) *HTTPServer {
+	return &HTTPServer{
+		handler:       NewHandler(mcpServer, promptManager),
+		promptManager: promptManager,
+		addr:          addr,
+		endpoint:      endpoint,
+		sessions:      make(map[string]*httpSession),
+		idleTimeout:   DefaultSessionIdleTimeout,
+	}
+}
+
+// SetSessionIdleTimeout 设置会话的空闲过期时间，0表示会话只在客户端DELETE或服务器关闭时结束
+func (s *HTTPServer) SetSessionIdleTimeout(timeout time.Duration) {
+	s.idleTimeout = timeout
+}
+
+// Start 启动服务器，收到SIGINT或SIGTERM后优雅关闭
+func (s *HTTPServer) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.endpoint, s.ServeHTTP)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if s.idleTimeout > 0 {
+		go s.expireSessions(ctx)
+	}
+
+	log.Printf("MCP Prompt Server is running on http://%s%s (Streamable HTTP)...", s.addr, s.endpoint)
+	if err := listenAndServe(s.addr, mux, s.closeAllSessions); err != nil {
+		return err
+	}
+
+	return s.promptManager.Close()
+}
+
+// ServeHTTP 处理MCP端点上的请求
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost 处理客户端发送的JSON-RPC消息或批量消息
+func (s *HTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	messages, batch, err := splitMessages(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, newErrorResponse(nil, -32700, "Parse error", err.Error()))
+		return
+	}
+
+	// initialize请求创建新会话，其余请求必须携带已有的会话ID
+	var hs *httpSession
+	initialize := containsInitialize(messages)
+	if initialize {
+		if len(messages) != 1 {
+			writeJSON(w, http.StatusBadRequest, newErrorResponse(nil, -32600, "Invalid Request", "initialize must not be part of a batch"))
+			return
+		}
+		hs = s.newSession()
+	} else {
+		var status int
+		hs, status = s.lookupSession(r)
+		if hs == nil {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		defer hs.touch()
+	}
+
+	// 客户端断开连接时取消请求
+	responses := s.handler.HandleMessages(r.Context(), hs.session, messages)
+
+	// 只有initialize成功时才登记会话并返回会话ID
+	if initialize {
+		if len(responses) == 1 && responses[0].Error == nil {
+			s.registerSession(hs)
+			w.Header().Set(sessionIDHeader, hs.session.ID)
+		} else {
+			s.handler.CloseSession(hs.session)
+		}
+	}
+
+	// 只包含通知或响应时返回202且没有响应体
+	reply := batchReply(responses, batch)
+	if reply == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if !acceptsEventStream(r) {
+		writeJSON(w, http.StatusOK, reply)
+		return
+	}
+
+	// 以SSE流的形式逐条返回响应
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	setEventStreamHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	for _, response := range responses {
+		data, err := json.Marshal(response)
+		if err != nil {
+			log.Printf("Failed to marshal response: %v", err)
+			continue
+		}
+		writeEvent(w, "message", data)
+	}
+	flusher.Flush()
+}
+
+// handleGet 为会话打开一个SSE流，用于推送服务器发起的通知
+func (s *HTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		http.Error(w, "Not acceptable: client must accept text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	hs, status := s.lookupSession(r)
+	if hs == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	stream := hs.openStream()
+	defer func() {
+		hs.closeStream(stream)
+		hs.touch()
+	}()
+
+	setEventStreamHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case data, ok := <-stream:
+			if !ok {
+				return
+			}
+			writeEvent(w, "message", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleDelete 由客户端显式结束会话
+func (s *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	hs, status := s.lookupSession(r)
+	if hs == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	s.closeSession(hs)
+	w.WriteHeader(http.StatusOK)
+}
+
+// newSession 为initialize请求创建会话，initialize成功后才通过registerSession登记
+func (s *HTTPServer) newSession() *httpSession {
+	hs := &httpSession{
+		streams:    make(map[chan []byte]struct{}),
+		lastActive: time.Now(),
+	}
+	hs.session = s.handler.NewSession(newSessionID(), hs.notify)
+	return hs
+}
+
+// registerSession 登记会话，之后的请求可以通过会话ID找到它
+func (s *HTTPServer) registerSession(hs *httpSession) {
+	s.sessionsMutex.Lock()
+	s.sessions[hs.session.ID] = hs
+	s.sessionsMutex.Unlock()
+
+	log.Printf("Created HTTP session %s", hs.session.ID)
+}
+
+// lookupSession 根据请求头查找会话，找不到时返回对应的HTTP状态码
+func (s *HTTPServer) lookupSession(r *http.Request) (*httpSession, int) {
+	id := r.Header.Get(sessionIDHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	s.sessionsMutex.RLock()
+	hs, exists := s.sessions[id]
+	s.sessionsMutex.RUnlock()
+
+	if !exists {
+		return nil, http.StatusNotFound
+	}
+
+	return hs, http.StatusOK
+}
+
+// closeSession 注销会话并关闭其所有SSE流
+func (s *HTTPServer) closeSession(hs *httpSession) {
+	s.sessionsMutex.Lock()
+	delete(s.sessions, hs.session.ID)
+	s.sessionsMutex.Unlock()
+
+	s.handler.CloseSession(hs.session)
+	hs.closeAllStreams()
+
+	log.Printf("Closed HTTP session %s", hs.session.ID)
+}
+
+// expireSessions 定期关闭空闲超时的会话，直到ctx取消
+func (s *HTTPServer) expireSessions(ctx context.Context) {
+	ticker := time.NewTicker(s.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.sessionsMutex.RLock()
+			var expired []*httpSession
+			for _, hs := range s.sessions {
+				if hs.idle(now, s.idleTimeout) {
+					expired = append(expired, hs)
+				}
+			}
+			s.sessionsMutex.RUnlock()
+
+			for _, hs := range expired {
+				log.Printf("HTTP session %s expired after %s of inactivity", hs.session.ID, s.idleTimeout)
+				s.closeSession(hs)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeAllSessions 关闭所有会话
+func (s *HTTPServer) closeAllSessions() {
+	s.sessionsMutex.RLock()
+	sessions := make([]*httpSession, 0, len(s.sessions))
+	for _, hs := range s.sessions {
+		sessions = append(sessions, hs)
+	}
+	s.sessionsMutex.RUnlock()
+
+	for _, hs := range sessions {
+		s.closeSession(hs)
+	}
+}
+
+// notify 将通知推送到会话的所有SSE流
+func (hs *httpSession) notify(notification *mcp.MCPNotification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	for stream := range hs.streams {
+		select {
+		case stream <- data:
+		default:
+			log.Printf("SSE stream of session %s is full, dropping %s", hs.session.ID, notification.Method)
+		}
+	}
+
+	return nil
+}
+
+// touch 记录会话的最近一次活动时间
+func (hs *httpSession) touch() {
+	hs.mutex.Lock()
+	hs.lastActive = time.Now()
+	hs.mutex.Unlock()
+}
+
+// idle 判断会话是否没有打开的SSE流且已空闲超过timeout
+func (hs *httpSession) idle(now time.Time, timeout time.Duration) bool {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	return len(hs.streams) == 0 && now.Sub(hs.lastActive) > timeout
+}
+
+// openStream 为会话登记一个新的SSE流
+func (hs *httpSession) openStream() chan []byte {
+	stream := make(chan []byte, streamBufferSize)
+
+	hs.mutex.Lock()
+	hs.streams[stream] = struct{}{}
+	hs.mutex.Unlock()
+
+	return stream
+}
+
+// closeStream 注销并关闭SSE流
+func (hs *httpSession) closeStream(stream chan []byte) {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	if _, exists := hs.streams[stream]; exists {
+		delete(hs.streams, stream)
+		close(stream)
+	}
+}
+
+// closeAllStreams 关闭会话的所有SSE流
+func (hs *httpSession) closeAllStreams() {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	for stream := range hs.streams {
+		delete(hs.streams, stream)
+		close(stream)
+	}
+}
+
+// listenAndServe 运行HTTP服务直到出错或收到SIGINT/SIGTERM，关闭前调用onShutdown断开长连接
+func listenAndServe(addr string, handler http.Handler, onShutdown func()) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to serve HTTP: %w", err)
+		}
+	case <-ctx.Done():
+		log.Println("Received shutdown signal, shutting down...")
+		onShutdown()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("HTTP server shutdown error: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// validOrigin 校验Origin头以防止DNS重绑定攻击，非浏览器客户端通常不带Origin
+func validOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
+
+// acceptsEventStream 判断客户端是否接受SSE
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+// setEventStreamHeaders 设置SSE响应头
+func setEventStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+}
+
+// writeEvent 写入一个SSE事件
+func writeEvent(w io.Writer, event string, data []byte) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Failed to write JSON response: %v", err)
+	}
+}
+
+// newSessionID 生成随机会话ID
+func newSessionID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
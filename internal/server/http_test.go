@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEndpoint 测试用的MCP端点
+const testEndpoint = "/mcp"
+
+// newTestHTTPServer 创建Streamable HTTP服务器及其httptest服务
+func newTestHTTPServer(t *testing.T) (*testEnv, *HTTPServer, *httptest.Server) {
+	t.Helper()
+
+	env := newTestEnv(t)
+	s := NewHTTPServer(env.server, env.manager, "", testEndpoint)
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		s.closeAllSessions()
+		ts.Close()
+	})
+
+	return env, s, ts
+}
+
+// post 向MCP端点发送JSON-RPC消息，sessionID为空时不带会话头
+func post(t *testing.T, ts *httptest.Server, sessionID, body string) *http.Response {
+	t.Helper()
+
+	resp, err := sendPost(ts, sessionID, body)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	return resp
+}
+
+// sendPost 发送POST请求，可在测试goroutine之外调用
+func sendPost(ts *httptest.Server, sessionID, body string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+testEndpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sessionID != "" {
+		req.Header.Set(sessionIDHeader, sessionID)
+	}
+
+	return ts.Client().Do(req)
+}
+
+// readBody 读取并关闭响应体
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return string(data)
+}
+
+// initialize 完成初始化握手并返回会话ID
+func initialize(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+
+	resp := post(t, ts, "", `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"test","version":"1"}}}`)
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, body %s", resp.StatusCode, body)
+	}
+
+	sessionID := resp.Header.Get(sessionIDHeader)
+	if sessionID == "" {
+		t.Fatalf("initialize returned no %s header", sessionIDHeader)
+	}
+
+	resp = post(t, ts, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("notifications/initialized status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+
+	return sessionID
+}
+
+func TestHTTPSessionLookup(t *testing.T) {
+	_, _, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts)
+
+	tests := []struct {
+		name       string
+		sessionID  string
+		wantStatus int
+	}{
+		{name: "missing session id", sessionID: "", wantStatus: http.StatusBadRequest},
+		{name: "unknown session id", sessionID: "unknown", wantStatus: http.StatusNotFound},
+		{name: "known session id", sessionID: sessionID, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := post(t, ts, tt.sessionID, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+			readBody(t, resp)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHTTPFailedInitializeCreatesNoSession(t *testing.T) {
+	_, s, ts := newTestHTTPServer(t)
+
+	resp := post(t, ts, "", `{"jsonrpc":"2.0","id":0,"method":"initialize","params":"bad"}`)
+	body := readBody(t, resp)
+
+	if !strings.Contains(body, `"code":-32602`) {
+		t.Errorf("body = %s, want invalid params error", body)
+	}
+	if id := resp.Header.Get(sessionIDHeader); id != "" {
+		t.Errorf("%s = %q, want none", sessionIDHeader, id)
+	}
+
+	s.sessionsMutex.RLock()
+	sessions := len(s.sessions)
+	s.sessionsMutex.RUnlock()
+	if sessions != 0 {
+		t.Errorf("registered sessions = %d, want 0", sessions)
+	}
+
+	s.handler.sessionsMutex.RLock()
+	handlerSessions := len(s.handler.sessions)
+	s.handler.sessionsMutex.RUnlock()
+	if handlerSessions != 0 {
+		t.Errorf("handler sessions = %d, want 0", handlerSessions)
+	}
+}
+
+func TestHTTPDeleteSession(t *testing.T) {
+	_, _, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts)
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+testEndpoint, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set(sessionIDHeader, sessionID)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("DELETE error = %v", err)
+	}
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = post(t, ts, sessionID, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status after DELETE = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHTTPPingNotBlockedBySlowCall(t *testing.T) {
+	env, _, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts)
+
+	slow := make(chan *http.Response, 1)
+	go func() {
+		resp, err := sendPost(ts, sessionID, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`)
+		if err != nil {
+			t.Errorf("POST error = %v", err)
+			close(slow)
+			return
+		}
+		slow <- resp
+	}()
+	waitSignal(t, env.slowStarted, "slow tool to start")
+
+	start := time.Now()
+	resp := post(t, ts, sessionID, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK || body != `{"jsonrpc":"2.0","id":2,"result":{}}`+"\n" {
+		t.Errorf("ping = %d %q", resp.StatusCode, body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ping took %s while a slow call was running", elapsed)
+	}
+
+	// 取消后慢请求不返回响应
+	resp = post(t, ts, sessionID, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)
+	readBody(t, resp)
+
+	resp = waitSignal(t, slow, "slow call to return")
+	if resp == nil {
+		return
+	}
+	body = readBody(t, resp)
+	if resp.StatusCode != http.StatusAccepted || body != "" {
+		t.Errorf("cancelled call = %d %q, want %d without body", resp.StatusCode, body, http.StatusAccepted)
+	}
+	if cancelled := waitSignal(t, env.slowDone, "slow tool to stop"); !cancelled {
+		t.Errorf("tool context was not cancelled")
+	}
+}
+
+func TestHTTPListChangedReachesStream(t *testing.T) {
+	env, _, ts := newTestHTTPServer(t)
+	sessionID := initialize(t, ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+testEndpoint, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionIDHeader, sessionID)
+
+	// 响应头返回时流已经打开
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	env.source.Set("other.yaml", []byte(`name: other
+messages:
+  - role: user
+    content:
+      type: text
+      text: other
+`))
+	if _, err := env.manager.LoadPrompts(); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+
+	want := `data: {"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}`
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed before list_changed")
+			}
+			if line == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestHTTPSessionIdleTimeout(t *testing.T) {
+	_, s, ts := newTestHTTPServer(t)
+	s.SetSessionIdleTimeout(50 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.expireSessions(ctx)
+
+	sessionID := initialize(t, ts)
+	time.Sleep(200 * time.Millisecond)
+
+	resp := post(t, ts, sessionID, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status of idle session = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
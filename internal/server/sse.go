@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"mcp-prompt-server/internal/mcp"
+	"mcp-prompt-server/internal/prompt"
+)
+
+const (
+	// 旧版HTTP+SSE传输（2024-11-05）的端点
+	sseEndpoint     = "/sse"
+	messageEndpoint = "/messages"
+
+	// 响应写入SSE流的最长等待时间
+	sseSendTimeout = 10 * time.Second
+)
+
+// SSEServer 旧版HTTP+SSE传输服务器，GET /sse 建立事件流，POST /messages 接收消息
+type SSEServer struct {
+	handler       *Handler
+	promptManager *prompt.Manager
+	addr          string
+
+	sessionsMutex sync.RWMutex
+	sessions      map[string]*sseSession
+}
+
+// sseSession 一个HTTP+SSE会话，所有响应和通知都通过其事件流发送
+type sseSession struct {
+	session  *Session
+	messages chan []byte
+	done     chan struct{}
+	once     sync.Once
+
+	// 请求的上下文随会话结束而取消，与POST连接无关
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewSSEServer 创建新的HTTP+SSE服务器
+func NewSSEServer(mcpServer *mcp.Server, promptManager *prompt.Manager, addr string) *SSEServer {
+	return &SSEServer{
+		handler:       NewHandler(mcpServer, promptManager),
+		promptManager: promptManager,
+		addr:          addr,
+		sessions:      make(map[string]*sseSession),
+	}
+}
+
+// Start 启动服务器，收到SIGINT或SIGTERM后优雅关闭
+func (s *SSEServer) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(sseEndpoint, s.handleSSE)
+	mux.HandleFunc(messageEndpoint, s.handleMessage)
+
+	log.Printf("MCP Prompt Server is running on http://%s%s (HTTP+SSE)...", s.addr, sseEndpoint)
+	if err := listenAndServe(s.addr, mux, s.closeAllSessions); err != nil {
+		return err
+	}
+
+	return s.promptManager.Close()
+}
+
+// handleSSE 建立事件流，先发送endpoint事件告知客户端消息端点
+func (s *SSEServer) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if !validOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ss := s.newSession()
+	defer s.closeSession(ss)
+
+	setEventStreamHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, "endpoint", []byte(fmt.Sprintf("%s?sessionId=%s", messageEndpoint, ss.session.ID)))
+	flusher.Flush()
+
+	for {
+		select {
+		case data := <-ss.messages:
+			writeEvent(w, "message", data)
+			flusher.Flush()
+		case <-ss.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleMessage 接收客户端消息，处理结果通过事件流异步返回
+func (s *SSEServer) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if !validOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("sessionId")
+	if id == "" {
+		http.Error(w, "Missing sessionId", http.StatusBadRequest)
+		return
+	}
+
+	s.sessionsMutex.RLock()
+	ss, exists := s.sessions[id]
+	s.sessionsMutex.RUnlock()
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	// 立即返回202，消息在后台处理，POST连接不等待处理完成
+	w.WriteHeader(http.StatusAccepted)
+	go s.dispatch(ss, body)
+}
+
+// dispatch 处理一条消息或批量消息并将响应写入事件流，请求的上下文随会话结束而取消
+func (s *SSEServer) dispatch(ss *sseSession, body []byte) {
+	reply := s.handler.HandlePayload(ss.ctx, ss.session, body)
+	if reply == nil {
+		return
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Failed to marshal response: %v", err)
+		return
+	}
+
+	if err := ss.send(data, sseSendTimeout); err != nil {
+		log.Printf("Failed to send response to session %s: %v", ss.session.ID, err)
+	}
+}
+
+// newSession 创建并登记新会话
+func (s *SSEServer) newSession() *sseSession {
+	ss := &sseSession{
+		messages: make(chan []byte, streamBufferSize),
+		done:     make(chan struct{}),
+	}
+	ss.ctx, ss.cancel = context.WithCancel(context.Background())
+	ss.session = s.handler.NewSession(newSessionID(), ss.notify)
+
+	s.sessionsMutex.Lock()
+	s.sessions[ss.session.ID] = ss
+	s.sessionsMutex.Unlock()
+
+	log.Printf("Created SSE session %s", ss.session.ID)
+	return ss
+}
+
+// closeSession 注销会话并结束其事件流
+func (s *SSEServer) closeSession(ss *sseSession) {
+	s.sessionsMutex.Lock()
+	delete(s.sessions, ss.session.ID)
+	s.sessionsMutex.Unlock()
+
+	s.handler.CloseSession(ss.session)
+	ss.once.Do(func() {
+		ss.cancel()
+		close(ss.done)
+		log.Printf("Closed SSE session %s", ss.session.ID)
+	})
+}
+
+// closeAllSessions 关闭所有会话
+func (s *SSEServer) closeAllSessions() {
+	s.sessionsMutex.RLock()
+	sessions := make([]*sseSession, 0, len(s.sessions))
+	for _, ss := range s.sessions {
+		sessions = append(sessions, ss)
+	}
+	s.sessionsMutex.RUnlock()
+
+	for _, ss := range sessions {
+		s.closeSession(ss)
+	}
+}
+
+// send 将消息放入事件流，会话关闭或超时时返回错误
+func (ss *sseSession) send(data []byte, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case ss.messages <- data:
+		return nil
+	case <-ss.done:
+		return fmt.Errorf("session closed")
+	case <-timer.C:
+		return fmt.Errorf("timed out")
+	}
+}
+
+// notify 将通知推送到事件流，缓冲满时丢弃
+func (ss *sseSession) notify(notification *mcp.MCPNotification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	select {
+	case ss.messages <- data:
+	case <-ss.done:
+	default:
+		log.Printf("SSE stream of session %s is full, dropping %s", ss.session.ID, notification.Method)
+	}
+
+	return nil
+}
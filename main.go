@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"mcp-prompt-server/internal/mcp"
 	"mcp-prompt-server/internal/prompt"
@@ -23,7 +33,29 @@ const (
 	promptsDir = "prompts"
 )
 
+// bundledPrompts 随二进制发布的默认prompt库，作为优先级最低的builtin来源
+//
+//go:embed prompts
+var bundledPrompts embed.FS
+
 func main() {
+	// 解析命令行参数
+	projectDir := flag.String("prompts-dir", promptsDir, "项目prompt目录，相对路径基于当前工作目录，优先级最高")
+	userDir := flag.String("user-prompts-dir", defaultUserPromptsDir(), "用户全局prompt目录，设为空字符串时不加载")
+	builtinPrompts := flag.Bool("builtin-prompts", true, "加载编译进程序的默认prompt库，优先级最低")
+	watch := flag.Bool("watch", true, "监控prompt目录，文件变化时自动重新加载")
+	reloadPolicy := flag.String("reload-policy", prompt.ReloadPolicyReplace, "重新加载时文件出错的处理方式: replace (移除出错文件中的prompt) 或 keep-last-good (保留上一次成功加载的版本)")
+	promptTools := flag.Bool("prompt-tools", true, "同时将prompts注册为MCP工具（prompts始终通过prompts/list和prompts/get提供）")
+	strict := flag.Bool("strict", false, "对所有prompt启用严格模式，渲染后残留未替换的占位符时报错")
+	timezone := flag.String("timezone", "", "内置变量ctx.date/ctx.time使用的IANA时区，如Asia/Shanghai，默认为本地时区")
+	workspace := flag.String("workspace", "", "内置变量ctx.workspace使用的工作区根目录，默认为当前工作目录")
+	transport := flag.String("transport", "stdio", "传输方式: stdio、http (Streamable HTTP) 或 sse (旧版HTTP+SSE)")
+	httpAddr := flag.String("http-addr", "127.0.0.1:8080", "http和sse传输的监听地址")
+	httpEndpoint := flag.String("http-endpoint", "/mcp", "HTTP传输的MCP端点路径")
+	sessionTimeout := flag.Duration("http-session-timeout", server.DefaultSessionIdleTimeout, "http传输中没有打开的SSE流且没有请求的会话在此时长后过期，0表示不过期")
+	toolNameSeparator := flag.String("tool-name-separator", prompt.NamespaceSeparator, "子目录prompt注册为工具时命名空间与名称之间的分隔符，客户端不接受点号时可设为_或__")
+	flag.Parse()
+
 	// 初始化日志
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
@@ -33,35 +65,88 @@ func main() {
 		log.Fatalf("Failed to get working directory: %v", err)
 	}
 
-	// 设置prompts目录路径
-	promptsDirPath := filepath.Join(workDir, promptsDir)
+	// 设置项目prompts目录路径，目录不存在时跳过，不会自动创建
+	promptsDirPath := *projectDir
+	if !filepath.IsAbs(promptsDirPath) {
+		promptsDirPath = filepath.Join(workDir, promptsDirPath)
+	}
 
-	// 确保prompts目录存在
-	if err := os.MkdirAll(promptsDirPath, 0755); err != nil {
-		log.Fatalf("Failed to create prompts directory: %v", err)
+	// 创建prompt管理器，来源按优先级从低到高排列
+	sources, err := promptSources(*builtinPrompts, *userDir, promptsDirPath)
+	if err != nil {
+		log.Fatalf("Failed to open built-in prompts: %v", err)
+	}
+	promptManager := prompt.NewManager(sources...)
+	promptManager.SetStrict(*strict)
+	if err := promptManager.SetReloadPolicy(*reloadPolicy); err != nil {
+		log.Fatalf("Invalid reload policy: %v", err)
 	}
 
-	// 创建prompt管理器
-	promptManager := prompt.NewManager(promptsDirPath)
+	// 设置内置变量的环境信息
+	env := prompt.Environment{
+		ServerVersion: Version,
+		Workspace:     workDir,
+	}
+	if *workspace != "" {
+		env.Workspace = *workspace
+	}
+	if *timezone != "" {
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			log.Fatalf("Invalid timezone %s: %v", *timezone, err)
+		}
+		env.Location = location
+	}
+	promptManager.SetEnvironment(env)
 
 	// 加载所有prompts
-	if err := promptManager.LoadPrompts(); err != nil {
+	if _, err := promptManager.LoadPrompts(); err != nil {
 		log.Fatalf("Failed to load prompts: %v", err)
 	}
 
 	// 创建MCP服务器
 	mcpServer := mcp.NewServer(serverName, Version)
 
-	// 注册prompt工具
-	if err := registerPromptTools(mcpServer, promptManager); err != nil {
-		log.Fatalf("Failed to register prompt tools: %v", err)
+	// 注册管理工具，先于prompt工具注册以便检测名称冲突
+	registerManagementTools(mcpServer, promptManager)
+
+	// 注册prompt工具，并在每次重新加载后保持同步
+	if *promptTools {
+		if *toolNameSeparator == "" {
+			log.Fatalf("Tool name separator cannot be empty")
+		}
+		registry := newPromptToolRegistry(mcpServer, promptManager, *toolNameSeparator)
+		if err := registry.Sync(); err != nil {
+			log.Fatalf("Failed to register prompt tools: %v", err)
+		}
+		promptManager.OnReload(func() {
+			if err := registry.Sync(); err != nil {
+				log.Printf("Failed to sync prompt tools: %v", err)
+			}
+		})
 	}
 
-	// 注册管理工具
-	registerManagementTools(mcpServer, promptManager)
+	// 启动文件监控，在注册回调之后启动，使热重载后工具列表同步更新
+	if *watch {
+		if err := promptManager.StartWatching(); err != nil {
+			log.Printf("Warning: Failed to start file watching: %v", err)
+		}
+	}
 
-	// 创建服务器实例
-	srv := server.New(mcpServer, promptManager)
+	// 根据传输方式创建服务器实例
+	var srv server.Transport
+	switch *transport {
+	case "stdio":
+		srv = server.New(mcpServer, promptManager)
+	case "http":
+		httpServer := server.NewHTTPServer(mcpServer, promptManager, *httpAddr, *httpEndpoint)
+		httpServer.SetSessionIdleTimeout(*sessionTimeout)
+		srv = httpServer
+	case "sse":
+		srv = server.NewSSEServer(mcpServer, promptManager, *httpAddr)
+	default:
+		log.Fatalf("Unknown transport: %s", *transport)
+	}
 
 	// 启动服务器
 	log.Printf("Starting MCP Prompt Server v%s (built: %s, commit: %s)...", Version, BuildTime, CommitHash)
@@ -70,31 +155,176 @@ func main() {
 	}
 }
 
-// registerPromptTools 注册所有prompt工具
-func registerPromptTools(mcpServer *mcp.Server, promptManager *prompt.Manager) error {
-	prompts := promptManager.GetPrompts()
+// defaultUserPromptsDir 返回用户全局prompt目录：$XDG_CONFIG_HOME/mcp-prompt-server/prompts，
+// 未设置XDG_CONFIG_HOME时为 ~/.config/mcp-prompt-server/prompts
+func defaultUserPromptsDir() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, serverName, promptsDir)
+}
+
+// promptSources 按内置、用户全局、项目的顺序组装来源，跳过未配置的目录；
+// 用户全局和项目目录相同时只保留项目来源
+func promptSources(builtin bool, userDir, projectDir string) ([]prompt.PromptSource, error) {
+	var sources []prompt.PromptSource
+
+	if builtin {
+		bundled, err := fs.Sub(bundledPrompts, promptsDir)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, prompt.NewFSSource(prompt.SourceBuiltin, "embed:"+promptsDir, bundled))
+	}
+
+	if userDir != "" {
+		if abs, err := filepath.Abs(userDir); err == nil {
+			userDir = abs
+		}
+		if userDir != projectDir {
+			sources = append(sources, prompt.NewDirSource(prompt.SourceUser, userDir))
+		}
+	}
+
+	return append(sources, prompt.NewDirSource(prompt.SourceProject, projectDir)), nil
+}
+
+// promptToolRegistry 维护由prompts生成的工具，使其与Manager保持同步
+type promptToolRegistry struct {
+	mcpServer     *mcp.Server
+	promptManager *prompt.Manager
+	separator     string
+	mutex         sync.Mutex
+	registered    map[string]bool
+}
+
+// newPromptToolRegistry 创建prompt工具注册表，separator用于连接命名空间和名称
+func newPromptToolRegistry(mcpServer *mcp.Server, promptManager *prompt.Manager, separator string) *promptToolRegistry {
+	return &promptToolRegistry{
+		mcpServer:     mcpServer,
+		promptManager: promptManager,
+		separator:     separator,
+		registered:    make(map[string]bool),
+	}
+}
+
+// Sync 根据当前prompts注册或更新工具，并注销已不存在的prompt对应的工具
+func (r *promptToolRegistry) Sync() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	prompts := r.promptManager.GetPrompts()
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+	current := make(map[string]bool, len(prompts))
+	owners := make(map[string]string, len(prompts))
 
 	for _, p := range prompts {
+		name := p.ToolName(r.separator)
+
+		// 不同命名空间的prompt在替换分隔符后可能得到相同的工具名，也不能覆盖管理工具
+		if owner, exists := owners[name]; exists {
+			log.Printf("Error: Tool name collision: prompt '%s' maps to tool '%s' already used by prompt '%s', skipping", p.Name, name, owner)
+			continue
+		}
+		if _, exists := r.mcpServer.GetTool(name); exists && !r.registered[name] {
+			log.Printf("Error: Tool name collision: prompt '%s' maps to tool '%s' already registered by the server, skipping", p.Name, name)
+			continue
+		}
+
 		tool := &mcp.Tool{
-			Name:        p.Name,
+			Name:        name,
 			Description: p.Description,
 			Arguments:   buildArgumentSchema(p.Arguments),
+			Meta:        p.Meta(),
 			Handler: func(prompt *prompt.Prompt) mcp.ToolHandler {
-				return func(args map[string]interface{}) (*mcp.ToolResult, error) {
-					return executePrompt(prompt, args)
+				return func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+					return executePrompt(ctx, r.promptManager, prompt, args)
 				}
 			}(p),
 		}
 
-		if err := mcpServer.RegisterTool(tool); err != nil {
-			return fmt.Errorf("failed to register tool %s: %w", p.Name, err)
+		if err := r.mcpServer.RegisterTool(tool); err != nil {
+			return fmt.Errorf("failed to register tool %s: %w", name, err)
 		}
+		current[name] = true
+		owners[name] = p.Name
 	}
 
-	log.Printf("Registered %d prompt tools", len(prompts))
+	// 注销已删除或重命名的prompt工具
+	removed := 0
+	for name := range r.registered {
+		if !current[name] {
+			r.mcpServer.UnregisterTool(name)
+			removed++
+		}
+	}
+	r.registered = current
+
+	log.Printf("Registered %d prompt tools (%d removed)", len(current), removed)
 	return nil
 }
 
+// formatReloadDiff 将重新加载的变化格式化为文本
+func formatReloadDiff(diff *prompt.ReloadDiff) string {
+	if diff.Empty() {
+		return "没有变化。\n"
+	}
+
+	var text strings.Builder
+	sections := []struct {
+		label string
+		names []string
+	}{
+		{"新增", diff.Added},
+		{"删除", diff.Removed},
+		{"修改", diff.Changed},
+		{"文件出错，保留旧版本", diff.Kept},
+	}
+	for _, section := range sections {
+		if len(section.names) == 0 {
+			continue
+		}
+		text.WriteString(fmt.Sprintf("%s (%d):\n", section.label, len(section.names)))
+		for _, name := range section.names {
+			text.WriteString("- " + name + "\n")
+		}
+	}
+
+	return text.String()
+}
+
+// formatDiagnostics 将加载错误格式化为文本，没有错误时返回空字符串
+func formatDiagnostics(diagnostics []prompt.Diagnostic) string {
+	if len(diagnostics) == 0 {
+		return ""
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("加载错误 (%d):\n", len(diagnostics)))
+	for i := range diagnostics {
+		text.WriteString("- " + diagnostics[i].Error() + "\n")
+	}
+
+	return text.String()
+}
+
+// promptFilter 从工具参数中读取tag和category过滤条件
+func promptFilter(args map[string]interface{}) prompt.Filter {
+	tag, _ := args["tag"].(string)
+	category, _ := args["category"].(string)
+	return prompt.Filter{
+		Tag:      strings.TrimSpace(tag),
+		Category: strings.TrimSpace(category),
+	}
+}
+
 // registerManagementTools 注册管理工具
 func registerManagementTools(mcpServer *mcp.Server, promptManager *prompt.Manager) {
 	// 重新加载prompts工具
@@ -102,8 +332,9 @@ func registerManagementTools(mcpServer *mcp.Server, promptManager *prompt.Manage
 		Name:        "reload_prompts",
 		Description: "重新加载所有预设的prompts",
 		Arguments:   map[string]interface{}{},
-		Handler: func(args map[string]interface{}) (*mcp.ToolResult, error) {
-			if err := promptManager.LoadPrompts(); err != nil {
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			diff, err := promptManager.LoadPrompts()
+			if err != nil {
 				return &mcp.ToolResult{
 					Content: []mcp.Content{{
 						Type: "text",
@@ -116,7 +347,64 @@ func registerManagementTools(mcpServer *mcp.Server, promptManager *prompt.Manage
 			return &mcp.ToolResult{
 				Content: []mcp.Content{{
 					Type: "text",
-					Text: fmt.Sprintf("成功重新加载了 %d 个prompts。", count),
+					Text: fmt.Sprintf("成功重新加载了 %d 个prompts。\n%s%s", count, formatReloadDiff(diff), formatDiagnostics(promptManager.Diagnostics())),
+				}},
+			}, nil
+		},
+	}
+
+	// 查看加载错误工具
+	loadErrorsTool := &mcp.Tool{
+		Name:        "get_load_errors",
+		Description: "查看最近一次加载prompts时出错的文件、位置和原因",
+		Arguments:   map[string]interface{}{},
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			diagnostics := promptManager.Diagnostics()
+			text := formatDiagnostics(diagnostics)
+			if len(diagnostics) == 0 {
+				text = "最近一次加载没有错误。\n"
+			}
+
+			return &mcp.ToolResult{
+				Content: []mcp.Content{{
+					Type: "text",
+					Text: text,
+				}},
+			}, nil
+		},
+	}
+
+	// 查看prompt来源工具
+	sourcesTool := &mcp.Tool{
+		Name:        "get_prompt_sources",
+		Description: "查看prompt来源目录的叠加顺序，以及每个prompt来自哪个来源、覆盖了哪些来源",
+		Arguments:   map[string]interface{}{},
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			var text strings.Builder
+			text.WriteString("来源（按优先级从低到高）:\n")
+			for _, source := range promptManager.Sources() {
+				text.WriteString(fmt.Sprintf("- %s: %s\n", source.Name(), source.Location()))
+			}
+
+			names := promptManager.GetPromptNames()
+			sort.Strings(names)
+			text.WriteString(fmt.Sprintf("\nprompts (%d):\n", len(names)))
+			for _, name := range names {
+				origin, exists := promptManager.GetOrigin(name)
+				if !exists {
+					continue
+				}
+				line := fmt.Sprintf("- %s [%s] %s", name, origin.Source, origin.Path)
+				if len(origin.Overridden) > 0 {
+					line += fmt.Sprintf("（覆盖 %s）", strings.Join(origin.Overridden, ", "))
+				}
+				text.WriteString(line + "\n")
+			}
+
+			return &mcp.ToolResult{
+				Content: []mcp.Content{{
+					Type: "text",
+					Text: text.String(),
 				}},
 			}, nil
 		},
@@ -125,14 +413,26 @@ func registerManagementTools(mcpServer *mcp.Server, promptManager *prompt.Manage
 	// 获取prompt名称列表工具
 	listTool := &mcp.Tool{
 		Name:        "get_prompt_names",
-		Description: "获取所有可用的prompt名称",
-		Arguments:   map[string]interface{}{},
-		Handler: func(args map[string]interface{}) (*mcp.ToolResult, error) {
-			prompts := promptManager.GetPrompts()
-			names := make([]string, len(prompts))
-			for i, p := range prompts {
-				names[i] = p.Name
+		Description: "获取所有可用的prompt名称，可按标签或分类过滤",
+		Arguments: map[string]interface{}{
+			"tag": map[string]interface{}{
+				"type":        "string",
+				"description": "只返回带有该标签的prompt",
+			},
+			"category": map[string]interface{}{
+				"type":        "string",
+				"description": "只返回该分类下的prompt",
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			filter := promptFilter(args)
+			names := make([]string, 0)
+			for _, p := range promptManager.GetPrompts() {
+				if filter.Match(p) {
+					names = append(names, p.Name)
+				}
 			}
+			sort.Strings(names)
 
 			result := fmt.Sprintf("可用的prompts (%d):\n", len(names))
 			for _, name := range names {
@@ -148,43 +448,159 @@ func registerManagementTools(mcpServer *mcp.Server, promptManager *prompt.Manage
 		},
 	}
 
+	// 查看统计信息工具
+	statsTool := &mcp.Tool{
+		Name:        "get_prompt_stats",
+		Description: "查看已加载prompt的数量、来源、参数、分类和标签分布，以及文件监控状态",
+		Arguments:   map[string]interface{}{},
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			data, err := json.MarshalIndent(promptManager.Stats(), "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal stats: %w", err)
+			}
+
+			return &mcp.ToolResult{
+				Content: []mcp.Content{{
+					Type: "text",
+					Text: string(data),
+				}},
+			}, nil
+		},
+	}
+
+	// 按相关度搜索prompt工具
+	searchTool := &mcp.Tool{
+		Name:        "search_prompts",
+		Description: "根据用途描述搜索最相关的prompt，按相关度排序返回名称和说明，可按标签或分类过滤",
+		Arguments: map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "要搜索的用途或关键词，例如“生成网页”或“code review”",
+				"required":    true,
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "最多返回的结果数量",
+				"default":     5,
+				"minimum":     1,
+			},
+			"tag": map[string]interface{}{
+				"type":        "string",
+				"description": "只返回带有该标签的prompt",
+			},
+			"category": map[string]interface{}{
+				"type":        "string",
+				"description": "只返回该分类下的prompt",
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]interface{}) (*mcp.ToolResult, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return argumentErrorResult(prompt.ArgumentErrors{{Name: "query", Message: "missing required argument"}}), nil
+			}
+
+			limit := 5
+			if value, ok := args["limit"].(float64); ok && value >= 1 {
+				limit = int(value)
+			}
+
+			results := promptManager.Search(query, limit, promptFilter(args))
+			if len(results) == 0 {
+				return &mcp.ToolResult{
+					Content: []mcp.Content{{
+						Type: "text",
+						Text: fmt.Sprintf("没有找到与“%s”相关的prompt。", query),
+					}},
+				}, nil
+			}
+
+			var text strings.Builder
+			text.WriteString(fmt.Sprintf("与“%s”相关的prompts (%d):\n", query, len(results)))
+			for i, result := range results {
+				text.WriteString(fmt.Sprintf("%d. %s (score %.2f)\n   %s\n", i+1, result.Prompt.Name, result.Score, result.Prompt.Description))
+			}
+
+			return &mcp.ToolResult{
+				Content: []mcp.Content{{
+					Type: "text",
+					Text: text.String(),
+				}},
+			}, nil
+		},
+	}
+
 	mcpServer.RegisterTool(reloadTool)
+	mcpServer.RegisterTool(loadErrorsTool)
+	mcpServer.RegisterTool(sourcesTool)
 	mcpServer.RegisterTool(listTool)
+	mcpServer.RegisterTool(statsTool)
+	mcpServer.RegisterTool(searchTool)
 
-	log.Println("Registered management tools: reload_prompts, get_prompt_names")
+	log.Println("Registered management tools: reload_prompts, get_load_errors, get_prompt_sources, get_prompt_names, get_prompt_stats, search_prompts")
 }
 
 // buildArgumentSchema 构建参数schema
 func buildArgumentSchema(args []prompt.Argument) map[string]interface{} {
 	schema := make(map[string]interface{})
 
-	for _, arg := range args {
-		argSchema := map[string]interface{}{
-			"type":        "string",
-			"description": arg.Description,
-		}
+	for i := range args {
+		argSchema := args[i].Schema()
 
-		if arg.Required {
+		if args[i].IsRequired() {
 			argSchema["required"] = true
 		}
 
-		schema[arg.Name] = argSchema
+		schema[args[i].Name] = argSchema
 	}
 
 	return schema
 }
 
 // executePrompt 执行prompt并返回结果
-func executePrompt(p *prompt.Prompt, args map[string]interface{}) (*mcp.ToolResult, error) {
-	content, err := p.Execute(args)
+func executePrompt(ctx context.Context, promptManager *prompt.Manager, p *prompt.Prompt, args map[string]interface{}) (*mcp.ToolResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	client, _ := mcp.ClientInfoFromContext(ctx)
+	messages, err := p.Execute(args, promptManager.BuiltinVariables(client.Name, client.Version))
 	if err != nil {
+		// 参数错误作为工具错误结果返回，便于模型修正参数后重试
+		var argErrs prompt.ArgumentErrors
+		if errors.As(err, &argErrs) {
+			return argumentErrorResult(argErrs), nil
+		}
 		return nil, fmt.Errorf("failed to execute prompt: %w", err)
 	}
 
+	// 每条消息对应一个内容块，并标注其角色
+	result := &mcp.ToolResult{
+		Content: make([]mcp.Content, 0, len(messages)),
+	}
+	for _, message := range messages {
+		result.Content = append(result.Content, mcp.Content{
+			Type:        "text",
+			Text:        message.Content.Text,
+			Annotations: &mcp.Annotations{Role: message.Role},
+		})
+	}
+
+	return result, nil
+}
+
+// argumentErrorResult 将参数错误列表转换为isError工具结果
+func argumentErrorResult(argErrs prompt.ArgumentErrors) *mcp.ToolResult {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("参数错误 (%d):\n", len(argErrs)))
+	for _, argErr := range argErrs {
+		text.WriteString(fmt.Sprintf("- %s: %s\n", argErr.Name, argErr.Message))
+	}
+
 	return &mcp.ToolResult{
 		Content: []mcp.Content{{
 			Type: "text",
-			Text: content,
+			Text: text.String(),
 		}},
-	}, nil
+		IsError: true,
+	}
 }